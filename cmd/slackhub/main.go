@@ -4,17 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sethvargo/go-githubactions"
 
 	"github.com/spaceweasel/slackhub/pkg/config"
+	"github.com/spaceweasel/slackhub/pkg/github"
 	"github.com/spaceweasel/slackhub/pkg/handler"
 	"github.com/spaceweasel/slackhub/pkg/sender"
 )
 
 type EventContext struct {
 	ctx       context.Context
+	id        string // workflow run or delivery
 	channel   string
 	actor     string
 	eventName string // e.g. pull_request
@@ -26,6 +32,10 @@ func (e *EventContext) Context() context.Context {
 	return e.ctx
 }
 
+func (e *EventContext) ID() string {
+	return e.id
+}
+
 func (e *EventContext) Channel() string {
 	return e.channel
 }
@@ -47,6 +57,11 @@ func (e *EventContext) QualifiedAction() string {
 	return e.eventName
 }
 
+// Matches reports whether the event name or qualified action is in m.
+func (e *EventContext) Matches(m map[string]bool) bool {
+	return m[e.QualifiedAction()] || m[e.eventName]
+}
+
 func (e *EventContext) Action() string {
 	action := e.Get("action")
 	if action != nil {
@@ -99,8 +114,10 @@ func get(v any, key string) any {
 
 func run(action *githubactions.Action) (err error) {
 	cfg := config.New(action)
-	poster := sender.NewPoster(cfg.Slack.Token)
-	hdlr := handler.New(poster)
+	poster := cfg.Poster
+	if cfg.DryRun {
+		poster = sender.NewRecordingPoster(action)
+	}
 
 	defer func() {
 		if err != nil {
@@ -111,14 +128,14 @@ func run(action *githubactions.Action) (err error) {
 		}
 	}()
 
-	c, err := action.Context()
+	hdlr, err := newHandler(cfg, poster)
 	if err != nil {
-		return fmt.Errorf("failed to get action context, %v", err)
+		return err
 	}
 
-	if cfg.SkipBots && strings.HasSuffix(c.Actor, "[bot]") {
-		action.Infof("Skipping bot actor: %s", c.Actor)
-		return nil
+	c, err := action.Context()
+	if err != nil {
+		return fmt.Errorf("failed to get action context, %v", err)
 	}
 
 	ec := &EventContext{
@@ -129,40 +146,167 @@ func run(action *githubactions.Action) (err error) {
 		ctx:       context.Background(),
 		sha:       c.SHA,
 	}
+	// scheduled events have no repository, which reminders need
+	if repo := action.Getenv("GITHUB_REPOSITORY"); c.Event != nil && c.Event["repository"] == nil {
+		owner, name, _ := strings.Cut(repo, "/")
+		c.Event["repository"] = map[string]any{
+			"full_name": repo,
+			"html_url":  c.ServerURL + "/" + repo,
+			"name":      name,
+			"owner":     map[string]any{"login": owner},
+		}
+	}
+	if c.EventName == "schedule" && cfg.ScheduleMode == "digest" && c.Event != nil {
+		c.Event["action"] = "digest"
+	}
+	// re-runs keep the run ID, so messages aren't posted twice
+	if c.RunID != 0 {
+		ec.id = strconv.FormatInt(c.RunID, 10)
+	}
+
+	return process(cfg, hdlr, ec)
+}
+
+func newHandler(cfg *config.Config, poster handler.Poster) (*handler.Handler, error) {
+	backend, ok := handler.Backends[cfg.Backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", cfg.Backend)
+	}
+
+	opts := []handler.Option{
+		handler.WithBackend(backend),
+		handler.WithLogger(cfg.Log),
+		handler.WithUsers(cfg.UserMap),
+		handler.WithCommitLimit(cfg.CommitLimit),
+		handler.WithStaleReminders(time.Duration(cfg.StaleAfterHours)*time.Hour, cfg.StaleIgnore),
+		handler.WithDigestWindow(time.Duration(cfg.DigestDays) * 24 * time.Hour),
+		handler.WithDebounce(time.Duration(cfg.DebounceMinutes) * time.Minute),
+	}
+	// dry runs mustn't affect later runs
+	if cfg.Store != nil && !cfg.DryRun {
+		opts = append(opts, handler.WithStore(cfg.Store))
+	}
+	if cfg.GitHub.Token != "" {
+		gh := github.NewClient(cfg.GitHub.Token, github.WithBaseURL(cfg.GitHub.APIURL))
+		opts = append(opts, handler.WithGitHub(gh))
+	}
+	if cfg.AggregateReview {
+		opts = append(opts, handler.WithReviewAggregation())
+	}
+	if reactor, ok := poster.(handler.Reactor); ok && cfg.Reactions != nil {
+		opts = append(opts, handler.WithReactions(reactor, cfg.Reactions))
+	}
+	// direct messages need a poster able to open conversations
+	if opener, ok := poster.(handler.Opener); ok {
+		switch cfg.DirectMessages {
+		case "also":
+			opts = append(opts, handler.WithDirectMessages(opener, false))
+		case "only":
+			opts = append(opts, handler.WithDirectMessages(opener, true))
+		}
+	}
+
+	return handler.New(poster, opts...), nil
+}
+
+// process filters the event, passing it to the handler unless ignored.
+func process(cfg *config.Config, hdlr *handler.Handler, ec *EventContext) error {
+	if cfg.SkipBots && strings.HasSuffix(ec.Actor(), "[bot]") {
+		cfg.Log.Infof("Skipping bot actor: %s", ec.Actor())
+		return nil
+	}
 
 	// ignore any marshalling errors
 	event, err := json.MarshalIndent(ec.event, "", "  ")
 	if err == nil {
-		action.Debugf("Event: %s", string(event))
+		if cfg.DumpEvent {
+			cfg.Log.Infof("Event: %s", string(event))
+		} else {
+			cfg.Log.Debugf("Event: %s", string(event))
+		}
 	}
 
-	if cfg.IgnoreActions[ec.QualifiedAction()] {
-		action.Infof("Ignoring action: %s", ec.QualifiedAction())
+	if ec.Matches(cfg.IgnoreActions) {
+		cfg.Log.Infof("Ignoring action: %s", ec.QualifiedAction())
 		return nil
 	}
 
-	if NewEventFilter().Ignore(ec) {
-		action.Infof("Filtering action: %s", ec.QualifiedAction())
-		return nil
+	// reactions stand in for messages that might otherwise be filtered
+	if reacted, err := hdlr.React(ec); err != nil || reacted {
+		return err
 	}
 
-	// Add pull_request.review_requested?
+	if NewEventFilter(cfg).Ignore(ec) {
+		cfg.Log.Infof("Filtering action: %s", ec.QualifiedAction())
+		return nil
+	}
 
-	err = hdlr.Handle(ec)
-	return err
+	return hdlr.Handle(ec)
 }
 
 func main() {
+	commands := map[string]func([]string) error{
+		"render": render,
+		"serve":  serve,
+	}
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	run(githubactions.New())
 }
 
+// optInActions are only processed when listed in the include_actions input,
+// either by event name or qualified action.
+var optInActions = map[string]bool{
+	"create": true,
+	"delete": true,
+	"fork":   true,
+	"star":   true,
+	"watch":  true,
+
+	"pull_request.labeled":             true,
+	"pull_request.unlabeled":           true,
+	"pull_request.edited":              true,
+	"pull_request.converted_to_draft":  true,
+	"pull_request.auto_merge_enabled":  true,
+	"pull_request.auto_merge_disabled": true,
+	"pull_request.locked":              true,
+	"pull_request.milestoned":          true,
+}
+
 type EventFilter struct {
 	cond []func(*EventContext) bool
 }
 
-func NewEventFilter() EventFilter {
+func NewEventFilter(cfg *config.Config) EventFilter {
 	f := EventFilter{
 		cond: []func(*EventContext) bool{
+			func(ec *EventContext) bool {
+				return ec.Matches(optInActions) && !ec.Matches(cfg.IncludeActions)
+			},
+			func(ec *EventContext) bool {
+				// only announce branches and tags matching the configured patterns
+				if ec.Name() != "create" && ec.Name() != "delete" {
+					return false
+				}
+				if len(cfg.RefPatterns) == 0 {
+					return false
+				}
+				ref, _ := ec.Get("ref").(string)
+				for _, pattern := range cfg.RefPatterns {
+					if ok, _ := path.Match(pattern, ref); ok {
+						return false
+					}
+				}
+				return true
+			},
 			func(ec *EventContext) bool {
 				if ec.QualifiedAction() != "pull_request.opened" {
 					return false
@@ -170,13 +314,19 @@ func NewEventFilter() EventFilter {
 				return ec.Get("draft") == true
 			},
 			func(ec *EventContext) bool {
-				if ec.QualifiedAction() != "pull_request.closed" {
+				if ec.QualifiedAction() != "pull_request.closed" || cfg.ClosedUnmerged {
 					return false
 				}
-				return ec.Get("merged") == false
+				return ec.Get("pull_request.merged") == false
+			},
+			func(ec *EventContext) bool {
+				// comments are listed with their review when it's submitted
+				return cfg.AggregateReview && ec.Name() == "pull_request_review_comment" &&
+					ec.Get("comment.pull_request_review_id") != nil
 			},
 			func(ec *EventContext) bool {
-				if ec.QualifiedAction() != "pull_request_review.submitted" {
+				// the handler skips reviews without comments when aggregating
+				if ec.QualifiedAction() != "pull_request_review.submitted" || cfg.AggregateReview {
 					return false
 				}
 				if ec.Get("review.state") == "approved" {
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/spaceweasel/slackhub/pkg/config"
+)
+
+func TestEventFilter_Ignore(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		name      string
+		eventName string
+		event     map[string]any
+		want      bool
+	}{
+		{
+			// drafts are still announced when opened
+			name:      "Draft opened",
+			eventName: "pull_request",
+			event:     map[string]any{"action": "opened", "pull_request": map[string]any{"draft": true}},
+		},
+		{
+			name:      "Opened",
+			eventName: "pull_request",
+			event:     map[string]any{"action": "opened", "pull_request": map[string]any{"draft": false}},
+		},
+		{
+			name:      "Draft ready for review",
+			eventName: "pull_request",
+			event:     map[string]any{"action": "ready_for_review", "pull_request": map[string]any{"draft": false}},
+		},
+	}
+
+	f := NewEventFilter(&config.Config{})
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			ec := newEventContext(context.Background(), "biscuits", "jeff", tt.eventName, tt.event)
+			c.Assert(f.Ignore(ec), qt.Equals, tt.want)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sethvargo/go-githubactions"
+
+	"github.com/spaceweasel/slackhub/pkg/config"
+	"github.com/spaceweasel/slackhub/pkg/handler"
+	"github.com/spaceweasel/slackhub/pkg/sender"
+)
+
+// render runs an event payload file through the filters and templates,
+// printing the message rather than posting it unless --send is given.
+// Inputs can be set with INPUT_ environment variables, as in a workflow.
+//
+//	slackhub render --event-name pull_request --event path.json
+func render(args []string) error {
+	return renderEvent(args, os.Getenv, os.Stdout, os.Stderr)
+}
+
+// renderEvent renders an event as render does, with inputs from getenv,
+// printing the message to stdout and logging to stderr.
+func renderEvent(args []string, getenv func(string) string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	eventName := fs.String("event-name", "", "name of the event, e.g. pull_request")
+	eventPath := fs.String("event", "", "path to the event payload JSON file")
+	channel := fs.String("channel", "", "Slack channel, defaults to the channel input")
+	actor := fs.String("actor", "", "GitHub login of the actor, defaults to the event sender")
+	send := fs.Bool("send", false, "post the message using SLACK_BOT_TOKEN or the webhook URL of the backend")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *eventName == "" || *eventPath == "" {
+		fs.Usage()
+		return errors.New("--event-name and --event are required")
+	}
+
+	b, err := os.ReadFile(*eventPath)
+	if err != nil {
+		return fmt.Errorf("could not read event, %w", err)
+	}
+	var event map[string]any
+	if err := json.Unmarshal(b, &event); err != nil {
+		return fmt.Errorf("could not parse event, %w", err)
+	}
+
+	// keep stdout for the payload
+	action := githubactions.New(
+		githubactions.WithGetenv(getenv),
+		githubactions.WithWriter(stderr),
+	)
+	cfg := config.New(action)
+	if *channel != "" {
+		cfg.Slack.Channel = *channel
+	}
+
+	ec := newEventContext(context.Background(), cfg.Slack.Channel, *actor, *eventName, event)
+
+	var poster handler.Poster = sender.NewWriterPoster(stdout)
+	if *send {
+		poster = cfg.Poster
+	} else {
+		cfg.Store = nil
+	}
+
+	hdlr, err := newHandler(cfg, poster)
+	if err != nil {
+		return err
+	}
+
+	return process(cfg, hdlr, ec)
+}
+
+// newEventContext creates the context for an event payload received outside
+// of a workflow run, defaulting the actor to the event sender.
+func newEventContext(ctx context.Context, channel, actor, eventName string, event map[string]any) *EventContext {
+	ec := &EventContext{
+		channel:   channel,
+		actor:     actor,
+		eventName: eventName,
+		event:     event,
+		ctx:       ctx,
+	}
+	if ec.actor == "" {
+		ec.actor, _ = ec.Get("sender.login").(string)
+	}
+	if sha, ok := ec.Get("after").(string); ok {
+		ec.sha = sha
+	} else if sha, ok := ec.Get("pull_request.head.sha").(string); ok {
+		ec.sha = sha
+	}
+
+	return ec
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRender(t *testing.T) {
+	c := qt.New(t)
+
+	env := map[string]string{
+		"INPUT_CHANNEL": "biscuits",
+	}
+	getenv := func(k string) string { return env[k] }
+
+	c.Run("Prints the message", func(c *qt.C) {
+		var stdout bytes.Buffer
+		err := renderEvent([]string{
+			"--event-name", "pull_request",
+			"--event", "../../pkg/handler/testdata/pull_request.reopened.json",
+		}, getenv, &stdout, io.Discard)
+		c.Assert(err, qt.IsNil)
+
+		var msg map[string]any
+		c.Assert(json.Unmarshal(stdout.Bytes(), &msg), qt.IsNil, qt.Commentf("%s", stdout.String()))
+		c.Assert(msg["channel"], qt.Equals, "biscuits")
+		att := msg["attachments"].([]any)[0].(map[string]any)
+		c.Assert(att["pretext"], qt.Matches, "Pull request re-opened by <https://github.com/.*")
+		c.Assert(att["title_link"], qt.Equals, "https://github.com/spaceweasel/jeff-test/pull/14")
+	})
+
+	c.Run("Channel flag", func(c *qt.C) {
+		var stdout bytes.Buffer
+		err := renderEvent([]string{
+			"--event-name", "star",
+			"--event", "../../pkg/handler/testdata/star.json",
+			"--channel", "custard",
+		}, func(k string) string {
+			// star is opt-in
+			if k == "INPUT_INCLUDE_ACTIONS" {
+				return "star"
+			}
+			return env[k]
+		}, &stdout, io.Discard)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stdout.String(), qt.Contains, `"channel": "custard"`)
+	})
+
+	c.Run("Filtered events print nothing", func(c *qt.C) {
+		var stdout bytes.Buffer
+		err := renderEvent([]string{
+			"--event-name", "pull_request",
+			"--event", "../../pkg/handler/testdata/pull_request.closed_unmerged.json",
+		}, getenv, &stdout, io.Discard)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stdout.String(), qt.Equals, "")
+
+		err = renderEvent([]string{
+			"--event-name", "star",
+			"--event", "../../pkg/handler/testdata/star.json",
+		}, getenv, &stdout, io.Discard)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stdout.String(), qt.Equals, "")
+	})
+
+	c.Run("Event required", func(c *qt.C) {
+		err := renderEvent([]string{"--event-name", "pull_request"}, getenv, io.Discard, io.Discard)
+		c.Assert(err, qt.ErrorMatches, "--event-name and --event are required")
+	})
+}
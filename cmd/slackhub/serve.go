@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+
+	"github.com/spaceweasel/slackhub/pkg/config"
+	"github.com/spaceweasel/slackhub/pkg/handler"
+	"github.com/spaceweasel/slackhub/pkg/sender"
+)
+
+// maxPayload is the largest payload GitHub delivers.
+const maxPayload = 25 << 20
+
+// serve receives GitHub webhooks, such as those of an organisation, handling
+// each event as the action would. Inputs can be set with INPUT_ environment
+// variables, as in a workflow.
+//
+//	GITHUB_WEBHOOK_SECRET=... slackhub serve --addr :8080
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	secret := os.Getenv("GITHUB_WEBHOOK_SECRET")
+	if secret == "" {
+		return errors.New("GITHUB_WEBHOOK_SECRET is required")
+	}
+
+	action := githubactions.New(githubactions.WithWriter(os.Stderr))
+	cfg := config.New(action)
+	poster := cfg.Poster
+	if cfg.DryRun {
+		poster = sender.NewWriterPoster(os.Stdout)
+	}
+	hdlr, err := newHandler(cfg, poster)
+	if err != nil {
+		return err
+	}
+
+	s := newServer(cfg, hdlr, []byte(secret))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// only ready once the port is bound
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("could not listen, %w", err)
+	}
+
+	return s.run(ctx, ln)
+}
+
+// run serves deliveries on a listener until the context is done, then shuts
+// down once those in flight are finished.
+func (s *server) run(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+	s.ready.Store(true)
+	s.cfg.Log.Infof("Listening on %s", ln.Addr())
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// fail readiness checks while deliveries in flight are finished
+	s.ready.Store(false)
+	s.cfg.Log.Infof("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return srv.Shutdown(ctx)
+}
+
+type server struct {
+	cfg    *config.Config
+	hdlr   *handler.Handler
+	secret []byte
+	ready  atomic.Bool
+	mux    *http.ServeMux
+}
+
+func newServer(cfg *config.Config, hdlr *handler.Handler, secret []byte) *server {
+	s := &server{
+		cfg:    cfg,
+		hdlr:   hdlr,
+		secret: secret,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/webhook", s.webhook)
+	s.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	s.mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	})
+
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// webhook handles a delivery, posting to the channel input unless overridden
+// with the channel query parameter.
+func (s *server) webhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayload))
+	if err != nil {
+		http.Error(w, "could not read payload", http.StatusBadRequest)
+		return
+	}
+	if !validSignature(s.secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	eventName := r.Header.Get("X-GitHub-Event")
+	delivery := r.Header.Get("X-GitHub-Delivery")
+	if eventName == "" {
+		http.Error(w, "missing X-GitHub-Event", http.StatusBadRequest)
+		return
+	}
+	if eventName == "ping" {
+		io.WriteString(w, "pong")
+		return
+	}
+
+	var event map[string]any
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "payload is not valid JSON", http.StatusBadRequest)
+		return
+	}
+
+	channel := s.cfg.Slack.Channel
+	if c := r.URL.Query().Get("channel"); c != "" {
+		channel = c
+	}
+	ec := newEventContext(r.Context(), channel, "", eventName, event)
+	ec.id = delivery
+
+	s.cfg.Log.Infof("Delivery %s: %s", delivery, ec.QualifiedAction())
+	if err := process(s.cfg, s.hdlr, ec); err != nil {
+		// the detail is logged rather than returned to the sender
+		s.cfg.Log.Errorf("Delivery %s: %v", delivery, err)
+		http.Error(w, "could not handle event", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validSignature reports whether sig is the HMAC of the body, e.g.
+// sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17.
+func validSignature(secret, body []byte, sig string) bool {
+	if !strings.HasPrefix(sig, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/sethvargo/go-githubactions"
+
+	"github.com/spaceweasel/slackhub/pkg/config"
+)
+
+func TestServer(t *testing.T) {
+	c := qt.New(t)
+
+	var posted []map[string]any
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/chat.postMessage")
+		var msg map[string]any
+		c.Check(json.NewDecoder(r.Body).Decode(&msg), qt.IsNil)
+		if msg["channel"] == "archived" {
+			io.WriteString(w, `{"ok":false,"error":"is_archived"}`)
+			return
+		}
+		posted = append(posted, msg)
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer slack.Close()
+
+	env := map[string]string{
+		"SLACK_BOT_TOKEN":     "xoxb-biscuits",
+		"INPUT_CHANNEL":       "biscuits",
+		"INPUT_SLACK_API_URL": slack.URL,
+	}
+	action := githubactions.New(
+		githubactions.WithGetenv(func(k string) string { return env[k] }),
+		githubactions.WithWriter(io.Discard),
+	)
+	cfg := config.New(action)
+	hdlr, err := newHandler(cfg, cfg.Poster)
+	c.Assert(err, qt.IsNil)
+
+	s := newServer(cfg, hdlr, []byte("custard"))
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// deliver posts a fixture, signed unless sig is given
+	deliver := func(url, event, fixture, sig string) *http.Response {
+		payload, err := os.ReadFile("../../pkg/handler/testdata/" + fixture + ".json")
+		c.Assert(err, qt.IsNil)
+		if sig == "" {
+			mac := hmac.New(sha256.New, []byte("custard"))
+			mac.Write(payload)
+			sig = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		}
+
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+		c.Assert(err, qt.IsNil)
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+		req.Header.Set("X-Hub-Signature-256", sig)
+		resp, err := http.DefaultClient.Do(req)
+		c.Assert(err, qt.IsNil)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		c.Assert(err, qt.IsNil)
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		return resp
+	}
+
+	c.Run("Not ready until listening", func(c *qt.C) {
+		resp, err := http.Get(srv.URL + "/readyz")
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusServiceUnavailable)
+
+		resp, err = http.Get(srv.URL + "/healthz")
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusOK)
+	})
+
+	c.Run("Ready while running", func(c *qt.C) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		c.Assert(err, qt.IsNil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan error, 1)
+		go func() {
+			done <- newServer(cfg, hdlr, []byte("custard")).run(ctx, ln)
+		}()
+
+		url := "http://" + ln.Addr().String() + "/readyz"
+		status := 0
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			resp, err := http.Get(url)
+			c.Assert(err, qt.IsNil)
+			resp.Body.Close()
+			if status = resp.StatusCode; status == http.StatusOK {
+				break
+			}
+		}
+		c.Assert(status, qt.Equals, http.StatusOK)
+
+		cancel()
+		c.Assert(<-done, qt.IsNil)
+		_, err = http.Get(url)
+		c.Assert(err, qt.Not(qt.IsNil))
+	})
+
+	c.Run("Invalid signature", func(c *qt.C) {
+		resp := deliver(srv.URL+"/webhook", "pull_request", "pull_request.reopened", "sha256=00")
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusUnauthorized)
+		c.Assert(posted, qt.HasLen, 0)
+	})
+
+	c.Run("Ping", func(c *qt.C) {
+		resp := deliver(srv.URL+"/webhook", "ping", "pull_request", "")
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusOK)
+		c.Assert(posted, qt.HasLen, 0)
+	})
+
+	c.Run("Opt-in action filtered", func(c *qt.C) {
+		resp := deliver(srv.URL+"/webhook", "pull_request", "pull_request.locked", "")
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusNoContent)
+		c.Assert(posted, qt.HasLen, 0)
+	})
+
+	c.Run("Event posted", func(c *qt.C) {
+		resp := deliver(srv.URL+"/webhook", "pull_request", "pull_request.reopened", "")
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusNoContent)
+		c.Assert(posted, qt.HasLen, 1)
+		c.Assert(posted[0]["channel"], qt.Equals, "biscuits")
+	})
+
+	c.Run("Channel overridden", func(c *qt.C) {
+		resp := deliver(srv.URL+"/webhook?channel=custard", "pull_request", "pull_request.reopened", "")
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusNoContent)
+		c.Assert(posted, qt.HasLen, 2)
+		c.Assert(posted[1]["channel"], qt.Equals, "custard")
+	})
+
+	c.Run("Error not returned to the sender", func(c *qt.C) {
+		resp := deliver(srv.URL+"/webhook?channel=archived", "pull_request", "pull_request.reopened", "")
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusInternalServerError)
+		b, err := io.ReadAll(resp.Body)
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(b), qt.Equals, "could not handle event\n")
+	})
+}
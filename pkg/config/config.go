@@ -1,64 +1,273 @@
 package config
 
 import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sethvargo/go-githubactions"
+
+	"github.com/spaceweasel/slackhub/pkg/handler"
+	"github.com/spaceweasel/slackhub/pkg/sender"
+	"github.com/spaceweasel/slackhub/pkg/store"
 )
 
 type Config struct {
 	Slack struct {
-		Token   string
-		Channel string
+		Token      string
+		WebhookURL string
+		Channel    string
+	}
+	// Backend is the chat platform messages are sent to, e.g. teams.
+	Backend string
+	// Poster sends messages to the backend, using the Slack bot token in
+	// preference to an incoming webhook.
+	Poster handler.Poster
+	// Store keeps refs to posted messages, or is nil.
+	Store  store.Store
+	GitHub struct {
+		Token  string
+		APIURL string
 	}
 	FailOnError     bool
 	DumpEvent       bool
+	DryRun          bool
 	PretextOverride string
 	IgnoreActions   map[string]bool
+	IncludeActions  map[string]bool
+	RefPatterns     []string
 	SkipBots        bool
+	ClosedUnmerged  bool
+	CommitLimit     int
+	StaleAfterHours int
+	StaleIgnore     map[string]bool
+	ScheduleMode    string
+	DigestDays      int
+	DebounceMinutes int
+	Reactions       map[string]string
+	AggregateReview bool
+	UserMap         map[string]string
+	DirectMessages  string
 	Log             Logger
 }
 
 func New(action *githubactions.Action) *Config {
-	action.AddMask("SLACK_BOT_TOKEN")
+	backend := strings.ToLower(action.GetInput("backend"))
+	if backend == "" {
+		backend = "slack"
+	}
+
+	token := action.Getenv("SLACK_BOT_TOKEN")
+	webhookURL := action.Getenv(webhookEnv[backend])
+	for _, secret := range []string{token, webhookURL} {
+		if secret != "" {
+			action.AddMask(secret)
+		}
+	}
+
 	cfg := &Config{
 		Slack: struct {
-			Token   string
-			Channel string
+			Token      string
+			WebhookURL string
+			Channel    string
 		}{
-			Token:   action.Getenv("SLACK_BOT_TOKEN"),
-			Channel: action.GetInput("channel"),
+			Token:      token,
+			WebhookURL: webhookURL,
+			Channel:    action.GetInput("channel"),
 		},
-		FailOnError:   strings.EqualFold(action.GetInput("fail_on_error"), "true"),
-		DumpEvent:     strings.EqualFold(action.GetInput("dump_event"), "true"),
-		IgnoreActions: strToMap(action.GetInput("ignore_actions")),
-		SkipBots:      strings.EqualFold(action.GetInput("skip_bots"), "true"),
+		GitHub: struct {
+			Token  string
+			APIURL string
+		}{
+			Token:  action.GetInput("github_token"),
+			APIURL: action.Getenv("GITHUB_API_URL"),
+		},
+		Backend:         backend,
+		FailOnError:     strings.EqualFold(action.GetInput("fail_on_error"), "true"),
+		DumpEvent:       strings.EqualFold(action.GetInput("dump_event"), "true"),
+		DryRun:          strings.EqualFold(action.GetInput("dry_run"), "true"),
+		IgnoreActions:   strToMap(action.GetInput("ignore_actions")),
+		IncludeActions:  strToMap(action.GetInput("include_actions")),
+		RefPatterns:     strToSlice(action.GetInput("ref_patterns")),
+		SkipBots:        strings.EqualFold(action.GetInput("skip_bots"), "true"),
+		ClosedUnmerged:  strings.EqualFold(action.GetInput("notify_closed_unmerged"), "true"),
+		CommitLimit:     strToInt(action.GetInput("push_commit_limit")),
+		StaleAfterHours: strToInt(action.GetInput("stale_after_hours")),
+		StaleIgnore:     strToMap(action.GetInput("stale_ignore_labels")),
+		ScheduleMode:    strings.ToLower(action.GetInput("schedule_mode")),
+		DigestDays:      strToInt(action.GetInput("digest_days")),
+		DebounceMinutes: strToInt(action.GetInput("debounce_minutes")),
+		AggregateReview: strings.EqualFold(action.GetInput("aggregate_reviews"), "true"),
+		UserMap:         strToPairs(action.GetInput("user_map")),
+		DirectMessages:  strings.ToLower(action.GetInput("direct_messages")),
 		Log: logger{
 			failOnErr: strings.EqualFold(action.GetInput("fail_on_error"), "true"),
 			l:         action,
 		},
 	}
 
+	opts := []sender.Option{
+		sender.WithBaseURL(action.GetInput("slack_api_url")),
+		sender.WithLogger(cfg.Log),
+	}
+	if path := action.GetInput("ca_bundle"); path != "" {
+		roots, err := loadCABundle(path)
+		if err != nil {
+			cfg.Log.Fatalf("could not load CA bundle, %v", err)
+		} else {
+			opts = append(opts, sender.WithRootCAs(roots))
+		}
+	}
+
+	if env, ok := webhookEnv[backend]; ok && backend != "slack" && webhookURL == "" {
+		// only slack can post with a bot token instead
+		cfg.Log.Fatalf("the %s backend needs %s", backend, env)
+	}
+	switch {
+	case backend == "mattermost":
+		cfg.Poster = sender.NewMattermostPoster(webhookURL, opts...)
+	case backend != "slack":
+		cfg.Poster = sender.NewWebhookPoster(webhookURL, opts...)
+	case token == "" && webhookURL != "":
+		cfg.Poster = sender.NewWebhookPoster(webhookURL, opts...)
+	default:
+		cfg.Poster = sender.NewPoster(token, opts...)
+	}
+
+	switch action.GetInput("store") {
+	case "":
+	case "memory":
+		cfg.Store = store.NewMemory()
+	case "file":
+		path := action.GetInput("store_path")
+		if path == "" {
+			path = ".slackhub/store.json"
+		}
+		cfg.Store = store.NewFile(path)
+	case "slack":
+		h, ok := cfg.Poster.(store.History)
+		if !ok {
+			cfg.Log.Fatalf("the slack store needs SLACK_BOT_TOKEN")
+			break
+		}
+		if !slackChannelID.MatchString(cfg.Slack.Channel) {
+			cfg.Log.Fatalf("the slack store needs the channel input to be a channel ID, e.g. C012AB3CD, not %q", cfg.Slack.Channel)
+			break
+		}
+		cfg.Store = store.NewSlack(h, cfg.Slack.Channel, slackStoreMaxAge)
+	default:
+		cfg.Log.Fatalf("unknown store %q", action.GetInput("store"))
+	}
+
+	if cfg.DebounceMinutes > 0 && cfg.Store == nil {
+		// each run of an action is a new process, so the memory store is empty
+		cfg.Log.Warningf("debounce_minutes needs a store, the slack store when run as an action, posting every event")
+	}
+
+	switch r := action.GetInput("reactions"); {
+	case r == "" || strings.EqualFold(r, "false"):
+	case strings.EqualFold(r, "true"):
+		cfg.Reactions = handler.DefaultReactions
+	default:
+		cfg.Reactions = make(map[string]string)
+		for k, v := range handler.DefaultReactions {
+			cfg.Reactions[k] = v
+		}
+		for k, v := range strToPairs(r) {
+			cfg.Reactions[k] = strings.Trim(v, ":")
+		}
+	}
+	if cfg.Reactions != nil && cfg.Store == nil {
+		cfg.Log.Warningf("reactions need a store to find pull request messages in")
+	}
+
 	return cfg
 }
 
+// slackStoreMaxAge is how far back the channel history is searched, matching
+// how long GitHub allows workflows to be re-run.
+const slackStoreMaxAge = 30 * 24 * time.Hour
+
+// slackChannelID matches the IDs of public, private and direct message
+// channels, which conversations.history needs rather than names.
+var slackChannelID = regexp.MustCompile(`^[CGD][A-Z0-9]{8,}$`)
+
+// loadCABundle adds the PEM encoded certificates in a file to the system
+// certificate authorities.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return roots, nil
+}
+
+// webhookEnv holds the environment variable containing the incoming webhook
+// URL for each backend.
+var webhookEnv = map[string]string{
+	"slack":      "SLACK_WEBHOOK_URL",
+	"mattermost": "MATTERMOST_WEBHOOK_URL",
+	"teams":      "TEAMS_WEBHOOK_URL",
+	"discord":    "DISCORD_WEBHOOK_URL",
+}
+
 func strToMap(s string) map[string]bool {
 	m := make(map[string]bool)
+	for _, el := range strToSlice(s) {
+		m[el] = true
+	}
+
+	return m
+}
+
+func strToInt(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
+
+// strToPairs parses a list of colon separated pairs, e.g. [octocat:U012AB3CD].
+func strToPairs(s string) map[string]string {
+	m := make(map[string]string)
+	for _, el := range strToSlice(s) {
+		k, v, ok := strings.Cut(el, ":")
+		if !ok {
+			continue
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	return m
+}
+
+func strToSlice(s string) []string {
 	if s == "" {
-		return m
+		return nil
 	}
 	if !strings.HasPrefix(s, "[") {
 		// single element
-		m[strings.TrimSpace(s)] = true
-		return m
+		return []string{strings.TrimSpace(s)}
 	}
 	s = strings.Trim(s, "[]")
 	els := strings.Split(s, ",")
+	sl := make([]string, 0, len(els))
 	for _, el := range els {
-		m[strings.TrimSpace(el)] = true
+		sl = append(sl, strings.TrimSpace(el))
 	}
 
-	return m
+	return sl
 }
 
 type Logger interface {
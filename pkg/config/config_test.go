@@ -0,0 +1,115 @@
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/sethvargo/go-githubactions"
+
+	"github.com/spaceweasel/slackhub/pkg/config"
+)
+
+func TestNew_Poster(t *testing.T) {
+	c := qt.New(t)
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Path + " " + r.Header.Get("Authorization")
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "Token in preference to webhook",
+			env: map[string]string{
+				"SLACK_BOT_TOKEN":   "xoxb-biscuits",
+				"SLACK_WEBHOOK_URL": srv.URL + "/webhook",
+			},
+			want: "/chat.postMessage Bearer xoxb-biscuits",
+		},
+		{
+			name: "Only token",
+			env:  map[string]string{"SLACK_BOT_TOKEN": "xoxb-biscuits"},
+			want: "/chat.postMessage Bearer xoxb-biscuits",
+		},
+		{
+			name: "Only webhook",
+			env:  map[string]string{"SLACK_WEBHOOK_URL": srv.URL + "/webhook"},
+			want: "/webhook ",
+		},
+		{
+			name: "Teams webhook",
+			env: map[string]string{
+				"INPUT_BACKEND":     "teams",
+				"SLACK_BOT_TOKEN":   "xoxb-biscuits",
+				"TEAMS_WEBHOOK_URL": srv.URL + "/teams",
+			},
+			want: "/teams ",
+		},
+		{
+			name: "Discord webhook",
+			env: map[string]string{
+				"INPUT_BACKEND":       "Discord",
+				"DISCORD_WEBHOOK_URL": srv.URL + "/discord",
+			},
+			want: "/discord ",
+		},
+		{
+			name: "Mattermost webhook",
+			env: map[string]string{
+				"INPUT_BACKEND":          "mattermost",
+				"MATTERMOST_WEBHOOK_URL": srv.URL + "/mattermost",
+			},
+			want: "/mattermost ",
+		},
+		{
+			name: "Teams without webhook",
+			env: map[string]string{
+				"INPUT_BACKEND":     "teams",
+				"SLACK_BOT_TOKEN":   "xoxb-biscuits",
+				"SLACK_WEBHOOK_URL": srv.URL + "/webhook",
+			},
+			wantErr: "the teams backend needs TEAMS_WEBHOOK_URL",
+		},
+		{
+			name:    "Discord without webhook",
+			env:     map[string]string{"INPUT_BACKEND": "discord"},
+			wantErr: "the discord backend needs DISCORD_WEBHOOK_URL",
+		},
+	}
+
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			got = ""
+			tt.env["INPUT_SLACK_API_URL"] = srv.URL
+			var out bytes.Buffer
+			action := githubactions.New(
+				githubactions.WithGetenv(func(k string) string { return tt.env[k] }),
+				githubactions.WithWriter(&out),
+			)
+
+			cfg := config.New(action)
+			if tt.wantErr != "" {
+				c.Assert(out.String(), qt.Contains, "::error::"+tt.wantErr)
+				return
+			}
+			c.Assert(out.String(), qt.Not(qt.Contains), "::error::")
+
+			err := cfg.Poster.Post(context.Background(), strings.NewReader(`{"channel":"biscuits","text":"Hi"}`))
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.Equals, tt.want)
+		})
+	}
+}
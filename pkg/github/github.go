@@ -0,0 +1,359 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultBaseURL = "https://api.github.com"
+
+type Client struct {
+	hc      *http.Client
+	baseURL string
+	token   string
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.hc = hc
+	}
+}
+
+// WithBaseURL sets the API URL, e.g. for GitHub Enterprise Server.
+func WithBaseURL(u string) Option {
+	return func(c *Client) {
+		if u != "" {
+			c.baseURL = strings.TrimSuffix(u, "/")
+		}
+	}
+}
+
+func NewClient(token string, opts ...Option) *Client {
+	c := &Client{
+		hc: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		baseURL: defaultBaseURL,
+		token:   token,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+type User struct {
+	Login string `json:"login"`
+}
+
+type Comment struct {
+	Body      string    `json:"body"`
+	HTMLURL   string    `json:"html_url"`
+	User      User      `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Label struct {
+	Name string `json:"name"`
+}
+
+type Team struct {
+	Slug    string `json:"slug"`
+	HTMLURL string `json:"html_url"`
+}
+
+type PullRequest struct {
+	Number             int        `json:"number"`
+	Title              string     `json:"title"`
+	HTMLURL            string     `json:"html_url"`
+	Draft              bool       `json:"draft"`
+	User               User       `json:"user"`
+	Labels             []Label    `json:"labels"`
+	RequestedReviewers []User     `json:"requested_reviewers"`
+	RequestedTeams     []Team     `json:"requested_teams"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	ClosedAt           *time.Time `json:"closed_at"`
+	MergedAt           *time.Time `json:"merged_at"`
+}
+
+type Review struct {
+	User        User      `json:"user"`
+	State       string    `json:"state"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// IssueEvent is something that happened to an issue or pull request, such as
+// a review being requested of a user or team.
+type IssueEvent struct {
+	Event             string    `json:"event"`
+	RequestedReviewer *User     `json:"requested_reviewer"`
+	RequestedTeam     *Team     `json:"requested_team"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ReviewComment is a comment on the diff of a pull request. Lines are zero if
+// the comment is on a whole file or outdated.
+type ReviewComment struct {
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	StartLine int    `json:"start_line"`
+	StartSide string `json:"start_side"`
+	DiffHunk  string `json:"diff_hunk"`
+	Body      string `json:"body"`
+	HTMLURL   string `json:"html_url"`
+}
+
+type Release struct {
+	Name        string    `json:"name"`
+	TagName     string    `json:"tag_name"`
+	HTMLURL     string    `json:"html_url"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+type WorkflowRun struct {
+	Name       string    `json:"name"`
+	HTMLURL    string    `json:"html_url"`
+	HeadBranch string    `json:"head_branch"`
+	Conclusion string    `json:"conclusion"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// maxPages limits how many pages of a list are fetched.
+const maxPages = 10
+
+// OpenPullRequests returns the open pull requests of a repository, oldest
+// first.
+func (c *Client) OpenPullRequests(ctx context.Context, repo string) ([]PullRequest, error) {
+	var pulls []PullRequest
+	for page := 1; page <= maxPages; page++ {
+		q := url.Values{}
+		q.Set("state", "open")
+		q.Set("sort", "created")
+		q.Set("direction", "asc")
+		q.Set("per_page", "100")
+		q.Set("page", fmt.Sprint(page))
+
+		var p []PullRequest
+		if err := c.get(ctx, fmt.Sprintf("/repos/%s/pulls", repo), q, &p); err != nil {
+			return nil, err
+		}
+		pulls = append(pulls, p...)
+		if len(p) < 100 {
+			break
+		}
+	}
+
+	return pulls, nil
+}
+
+// PullRequests returns the pull requests of a repository updated since a
+// time, most recently updated first.
+func (c *Client) PullRequests(ctx context.Context, repo string, since time.Time) ([]PullRequest, error) {
+	var pulls []PullRequest
+	for page := 1; page <= maxPages; page++ {
+		q := url.Values{}
+		q.Set("state", "all")
+		q.Set("sort", "updated")
+		q.Set("direction", "desc")
+		q.Set("per_page", "100")
+		q.Set("page", fmt.Sprint(page))
+
+		var p []PullRequest
+		if err := c.get(ctx, fmt.Sprintf("/repos/%s/pulls", repo), q, &p); err != nil {
+			return nil, err
+		}
+		for _, pr := range p {
+			if pr.UpdatedAt.Before(since) {
+				return pulls, nil
+			}
+			pulls = append(pulls, pr)
+		}
+		if len(p) < 100 {
+			break
+		}
+	}
+
+	return pulls, nil
+}
+
+// Reviews returns the reviews of a pull request, oldest first.
+func (c *Client) Reviews(ctx context.Context, repo string, number int) ([]Review, error) {
+	var reviews []Review
+	for page := 1; page <= maxPages; page++ {
+		q := url.Values{}
+		q.Set("per_page", "100")
+		q.Set("page", fmt.Sprint(page))
+
+		var r []Review
+		if err := c.get(ctx, fmt.Sprintf("/repos/%s/pulls/%d/reviews", repo, number), q, &r); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r...)
+		if len(r) < 100 {
+			break
+		}
+	}
+
+	return reviews, nil
+}
+
+// IssueEvents returns the events of an issue or pull request, oldest first.
+func (c *Client) IssueEvents(ctx context.Context, repo string, number int) ([]IssueEvent, error) {
+	var events []IssueEvent
+	for page := 1; page <= maxPages; page++ {
+		q := url.Values{}
+		q.Set("per_page", "100")
+		q.Set("page", fmt.Sprint(page))
+
+		var e []IssueEvent
+		if err := c.get(ctx, fmt.Sprintf("/repos/%s/issues/%d/events", repo, number), q, &e); err != nil {
+			return nil, err
+		}
+		events = append(events, e...)
+		if len(e) < 100 {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// ReviewComments returns the comments of a review of a pull request, in the
+// order they were made.
+func (c *Client) ReviewComments(ctx context.Context, repo string, number int, reviewID int64) ([]ReviewComment, error) {
+	var comments []ReviewComment
+	for page := 1; page <= maxPages; page++ {
+		q := url.Values{}
+		q.Set("per_page", "100")
+		q.Set("page", fmt.Sprint(page))
+
+		var rc []ReviewComment
+		if err := c.get(ctx, fmt.Sprintf("/repos/%s/pulls/%d/reviews/%d/comments", repo, number, reviewID), q, &rc); err != nil {
+			return nil, err
+		}
+		comments = append(comments, rc...)
+		if len(rc) < 100 {
+			break
+		}
+	}
+
+	return comments, nil
+}
+
+// Releases returns the releases of a repository published since a time, most
+// recent first. Pages are fetched until one holds a release published before
+// the time.
+func (c *Client) Releases(ctx context.Context, repo string, since time.Time) ([]Release, error) {
+	var published []Release
+	for page := 1; page <= maxPages; page++ {
+		q := url.Values{}
+		q.Set("per_page", "100")
+		q.Set("page", fmt.Sprint(page))
+
+		var releases []Release
+		if err := c.get(ctx, fmt.Sprintf("/repos/%s/releases", repo), q, &releases); err != nil {
+			return nil, err
+		}
+		older := false
+		for _, r := range releases {
+			if r.Draft {
+				continue
+			}
+			if r.PublishedAt.Before(since) {
+				older = true
+				continue
+			}
+			published = append(published, r)
+		}
+		if older || len(releases) < 100 {
+			break
+		}
+	}
+
+	return published, nil
+}
+
+// FailedRuns returns the workflow runs of a repository which failed since a
+// time, most recent first.
+func (c *Client) FailedRuns(ctx context.Context, repo string, since time.Time) ([]WorkflowRun, error) {
+	q := url.Values{}
+	q.Set("status", "failure")
+	q.Set("created", ">="+since.UTC().Format(time.RFC3339))
+	q.Set("per_page", "100")
+
+	var r struct {
+		WorkflowRuns []WorkflowRun `json:"workflow_runs"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/actions/runs", repo), q, &r); err != nil {
+		return nil, err
+	}
+
+	return r.WorkflowRuns, nil
+}
+
+// LastComment returns the most recent of the count comments on an issue or
+// pull request, or nil if there are none.
+func (c *Client) LastComment(ctx context.Context, repo string, number, count int) (*Comment, error) {
+	if count < 1 {
+		return nil, nil
+	}
+
+	q := url.Values{}
+	q.Set("per_page", "1")
+	q.Set("page", fmt.Sprint(count))
+
+	var comments []Comment
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), q, &comments); err != nil {
+		return nil, err
+	}
+	if len(comments) == 0 {
+		return nil, nil
+	}
+
+	return &comments[0], nil
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("GET %s failed with %d, %s", path, resp.StatusCode, e.Message)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,176 @@
+package github_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/spaceweasel/slackhub/pkg/github"
+)
+
+func TestClient_LastComment(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/repos/spaceweasel/jeff-test/issues/14/comments")
+		c.Check(r.URL.Query().Get("per_page"), qt.Equals, "1")
+		c.Check(r.URL.Query().Get("page"), qt.Equals, "3")
+		c.Check(r.Header.Get("Authorization"), qt.Equals, "Bearer t0ken")
+		w.Write([]byte(`[{"body":"Superseded by #15","user":{"login":"jeff"},"created_at":"2022-08-29T09:12:44Z"}]`))
+	}))
+	defer srv.Close()
+
+	gh := github.NewClient("t0ken", github.WithBaseURL(srv.URL))
+
+	comment, err := gh.LastComment(context.Background(), "spaceweasel/jeff-test", 14, 3)
+	c.Assert(err, qt.IsNil)
+	c.Assert(comment.Body, qt.Equals, "Superseded by #15")
+	c.Assert(comment.User.Login, qt.Equals, "jeff")
+
+	comment, err = gh.LastComment(context.Background(), "spaceweasel/jeff-test", 14, 0)
+	c.Assert(err, qt.IsNil)
+	c.Assert(comment, qt.IsNil)
+}
+
+func TestClient_ErrorResponse(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"Not Found"}`))
+	}))
+	defer srv.Close()
+
+	gh := github.NewClient("", github.WithBaseURL(srv.URL))
+
+	_, err := gh.LastComment(context.Background(), "spaceweasel/jeff-test", 14, 1)
+	c.Assert(err, qt.ErrorMatches, "GET /repos/spaceweasel/jeff-test/issues/14/comments failed with 404, Not Found")
+}
+
+func TestClient_OpenPullRequests(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/repos/spaceweasel/jeff-test/pulls")
+		c.Check(r.URL.Query().Get("state"), qt.Equals, "open")
+		if r.URL.Query().Get("page") == "1" {
+			w.Write([]byte("[" + strings.Repeat(`{"number":14},`, 99) + `{"number":14}]`))
+			return
+		}
+		w.Write([]byte(`[{"number":16,"draft":true,"requested_teams":[{"slug":"back-end-owner"}]}]`))
+	}))
+	defer srv.Close()
+
+	gh := github.NewClient("t0ken", github.WithBaseURL(srv.URL))
+
+	pulls, err := gh.OpenPullRequests(context.Background(), "spaceweasel/jeff-test")
+	c.Assert(err, qt.IsNil)
+	c.Assert(pulls, qt.HasLen, 101)
+	c.Assert(pulls[100].Number, qt.Equals, 16)
+	c.Assert(pulls[100].Draft, qt.IsTrue)
+	c.Assert(pulls[100].RequestedTeams[0].Slug, qt.Equals, "back-end-owner")
+}
+
+func TestClient_IssueEvents(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/repos/spaceweasel/jeff-test/issues/14/events")
+		if r.URL.Query().Get("page") == "1" {
+			w.Write([]byte("[" + strings.Repeat(`{"event":"labeled"},`, 99) + `{"event":"labeled"}]`))
+			return
+		}
+		w.Write([]byte(`[{"event":"review_requested","requested_reviewer":{"login":"togglebuild"},"created_at":"2022-09-05T10:00:00Z"}]`))
+	}))
+	defer srv.Close()
+
+	gh := github.NewClient("t0ken", github.WithBaseURL(srv.URL))
+
+	events, err := gh.IssueEvents(context.Background(), "spaceweasel/jeff-test", 14)
+	c.Assert(err, qt.IsNil)
+	c.Assert(events, qt.HasLen, 101)
+	c.Assert(events[100].Event, qt.Equals, "review_requested")
+	c.Assert(events[100].RequestedReviewer.Login, qt.Equals, "togglebuild")
+	c.Assert(events[100].RequestedTeam, qt.IsNil)
+	c.Assert(events[100].CreatedAt, qt.Equals, time.Date(2022, 9, 5, 10, 0, 0, 0, time.UTC))
+}
+
+func TestClient_Reviews(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/repos/spaceweasel/jeff-test/pulls/14/reviews")
+		if r.URL.Query().Get("page") == "1" {
+			w.Write([]byte("[" + strings.Repeat(`{"state":"COMMENTED"},`, 99) + `{"state":"COMMENTED"}]`))
+			return
+		}
+		w.Write([]byte(`[{"state":"APPROVED","user":{"login":"togglebuild"}}]`))
+	}))
+	defer srv.Close()
+
+	gh := github.NewClient("t0ken", github.WithBaseURL(srv.URL))
+
+	reviews, err := gh.Reviews(context.Background(), "spaceweasel/jeff-test", 14)
+	c.Assert(err, qt.IsNil)
+	c.Assert(reviews, qt.HasLen, 101)
+	c.Assert(reviews[100].State, qt.Equals, "APPROVED")
+	c.Assert(reviews[100].User.Login, qt.Equals, "togglebuild")
+}
+
+func TestClient_ReviewComments(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/repos/spaceweasel/jeff-test/pulls/14/reviews/1091552283/comments")
+		if r.URL.Query().Get("page") == "1" {
+			w.Write([]byte("[" + strings.Repeat(`{"body":"Nit"},`, 99) + `{"body":"Nit"}]`))
+			return
+		}
+		w.Write([]byte(`[{"body":"Spoons, not forks"}]`))
+	}))
+	defer srv.Close()
+
+	gh := github.NewClient("t0ken", github.WithBaseURL(srv.URL))
+
+	comments, err := gh.ReviewComments(context.Background(), "spaceweasel/jeff-test", 14, 1091552283)
+	c.Assert(err, qt.IsNil)
+	c.Assert(comments, qt.HasLen, 101)
+	c.Assert(comments[100].Body, qt.Equals, "Spoons, not forks")
+}
+
+func TestClient_Releases(t *testing.T) {
+	c := qt.New(t)
+
+	pages := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/repos/spaceweasel/jeff-test/releases")
+		pages++
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Write([]byte(`[{"tag_name":"v2.0.0","draft":true},` +
+				strings.Repeat(`{"tag_name":"v1.3.0","published_at":"2022-09-06T10:00:00Z"},`, 98) +
+				`{"tag_name":"v1.2.0","published_at":"2022-09-05T10:00:00Z"}]`))
+		case "2":
+			w.Write([]byte("[" + strings.Repeat(`{"tag_name":"v1.1.0","published_at":"2022-09-04T10:00:00Z"},`, 99) +
+				`{"tag_name":"v1.0.0","published_at":"2022-08-01T10:00:00Z"}]`))
+		default:
+			c.Errorf("unexpected page %s", r.URL.Query().Get("page"))
+		}
+	}))
+	defer srv.Close()
+
+	gh := github.NewClient("t0ken", github.WithBaseURL(srv.URL))
+
+	since := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	releases, err := gh.Releases(context.Background(), "spaceweasel/jeff-test", since)
+	c.Assert(err, qt.IsNil)
+	c.Assert(pages, qt.Equals, 2)
+	c.Assert(releases, qt.HasLen, 198)
+	c.Assert(releases[0].TagName, qt.Equals, "v1.3.0")
+	c.Assert(releases[197].TagName, qt.Equals, "v1.1.0")
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Backend is a chat platform, supplying the templates messages are rendered
+// with and how users and links are formatted within them.
+type Backend struct {
+	Name string
+	// Templates holds a template for each supported action, named
+	// <event>/<action>.tmpl.
+	Templates fs.FS
+	// Mention formats a mention of a chat user ID.
+	Mention func(id string) string
+	// Link formats a link to url.
+	Link func(url, text string) string
+	// Validate checks a rendered message against the platform limits,
+	// returning the message to send.
+	Validate func(b []byte) ([]byte, error)
+	// builders build the messages of some actions, rather than their
+	// templates.
+	builders map[string]builder
+}
+
+// Backends are the supported chat platforms, by name.
+var Backends = map[string]Backend{
+	"slack": {
+		Name:      "slack",
+		Templates: sub("templates/slack"),
+		Mention:   func(id string) string { return "<@" + id + ">" },
+		Link:      slackLink,
+		Validate:  validate,
+		builders:  slackBuilders,
+	},
+	// Mattermost accepts Slack messages, but mentions by username.
+	"mattermost": {
+		Name:      "mattermost",
+		Templates: sub("templates/slack"),
+		Mention:   func(id string) string { return "@" + id },
+		Link:      slackLink,
+		Validate:  validate,
+		builders:  slackBuilders,
+	},
+	// Teams mentions by user principal name or Azure AD object ID, listed in
+	// the msteams entities of the card when validated.
+	"teams": {
+		Name:      "teams",
+		Templates: sub("templates/teams"),
+		Mention:   func(id string) string { return "<at>" + id + "</at>" },
+		Link:      markdownLink,
+		Validate:  validateTeams,
+	},
+	"discord": {
+		Name:      "discord",
+		Templates: sub("templates/discord"),
+		Mention:   func(id string) string { return "<@" + id + ">" },
+		Link:      markdownLink,
+		Validate:  validateDiscord,
+	},
+}
+
+func sub(dir string) fs.FS {
+	f, err := fs.Sub(templates, dir)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func slackLink(url, text string) string {
+	return "<" + url + "|" + text + ">"
+}
+
+// markdownLink formats a link in markdown, escaped for JSON as it is always
+// within a string of the Teams and Discord templates.
+func markdownLink(url, text string) string {
+	return "[" + JSON(text) + "](" + JSON(url) + ")"
+}
+
+// JSON escapes a value for use within a JSON string, leaving markdown as is
+// for platforms which support it.
+func JSON(v any) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		s = v
+	default:
+		s = fmt.Sprint(v)
+	}
+	out := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	// unquote, keeping the escapes
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(out.String()), `"`), `"`)
+}
+
+// Teams rejects messages larger than about 28KB.
+const maxTeamsLen = 28000
+
+func validateTeams(b []byte) ([]byte, error) {
+	var msg map[string]any
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return nil, jsonError(b, err)
+	}
+
+	v := &validator{}
+	attachments, _ := msg["attachments"].([]any)
+	for _, a := range attachments {
+		att, _ := a.(map[string]any)
+		if card, ok := att["content"].(map[string]any); ok && mentionEntities(card) {
+			v.changed = true
+		}
+	}
+	b, err := v.result(b, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > maxTeamsLen {
+		return nil, fmt.Errorf("message is %d bytes, Teams allows at most %d", len(b), maxTeamsLen)
+	}
+	return b, nil
+}
+
+var teamsMention = regexp.MustCompile(`<at>([^<]+)</at>`)
+
+// mentionEntities lists the users mentioned in an Adaptive Card in its
+// msteams entities, without which Teams shows mentions as plain text,
+// reporting whether there were any.
+func mentionEntities(card map[string]any) bool {
+	var entities []any
+	seen := make(map[string]bool)
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case string:
+			for _, m := range teamsMention.FindAllStringSubmatch(v, -1) {
+				if seen[m[0]] {
+					continue
+				}
+				seen[m[0]] = true
+				entities = append(entities, map[string]any{
+					"type":      "mention",
+					"text":      m[0],
+					"mentioned": map[string]any{"id": m[1], "name": m[1]},
+				})
+			}
+		case map[string]any:
+			for _, k := range sortedKeys(v) {
+				walk(v[k])
+			}
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(card)
+	if len(entities) == 0 {
+		return false
+	}
+
+	card["msteams"] = map[string]any{"entities": entities}
+	return true
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Discord limits, beyond which messages are rejected.
+const (
+	maxDiscordContentLen     = 2000
+	maxDiscordEmbeds         = 10
+	maxDiscordTitleLen       = 256
+	maxDiscordDescriptionLen = 4096
+	maxDiscordFields         = 25
+	maxDiscordFieldLen       = 1024
+)
+
+func validateDiscord(b []byte) ([]byte, error) {
+	var msg map[string]any
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return nil, jsonError(b, err)
+	}
+
+	v := &validator{}
+	v.truncate(msg, "content", maxDiscordContentLen, "")
+
+	embeds, _ := msg["embeds"].([]any)
+	if len(embeds) > maxDiscordEmbeds {
+		return nil, fmt.Errorf("message has %d embeds, Discord allows at most %d", len(embeds), maxDiscordEmbeds)
+	}
+	for _, e := range embeds {
+		embed, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+		v.truncate(embed, "title", maxDiscordTitleLen, "")
+		v.truncate(embed, "description", maxDiscordDescriptionLen, "")
+		fields, _ := embed["fields"].([]any)
+		if len(fields) > maxDiscordFields {
+			return nil, fmt.Errorf("embed has %d fields, Discord allows at most %d", len(fields), maxDiscordFields)
+		}
+		for _, f := range fields {
+			if field, ok := f.(map[string]any); ok {
+				v.truncate(field, "value", maxDiscordFieldLen, "")
+			}
+		}
+	}
+
+	return v.result(b, msg)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestJSON(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(JSON("A \"quoted\" <title>\r\n& more"), qt.Equals, `A \"quoted\" <title>\r\n& more`)
+	c.Assert(JSON(14.0), qt.Equals, "14")
+	c.Assert(JSON(nil), qt.Equals, "")
+}
+
+func TestMarkdownLink(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(markdownLink(`https://github.com/spaceweasel/jeff-test/tree/fix"quote`, "`fix\"quote`"), qt.Equals,
+		"[`fix\\\"quote`](https://github.com/spaceweasel/jeff-test/tree/fix\\\"quote)")
+}
+
+func TestValidateDiscord(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("Long description truncated", func(c *qt.C) {
+		in, _ := json.Marshal(map[string]any{
+			"embeds": []any{map[string]any{
+				"description": strings.Repeat("custard ", 1000),
+			}},
+		})
+		out, err := validateDiscord(in)
+		c.Assert(err, qt.IsNil)
+
+		var msg struct {
+			Embeds []struct{ Description string }
+		}
+		c.Assert(json.Unmarshal(out, &msg), qt.IsNil)
+		description := msg.Embeds[0].Description
+		c.Assert(utf8.RuneCountInString(description) <= maxDiscordDescriptionLen, qt.IsTrue)
+		c.Assert(description, qt.Matches, `(custard )*custard…`)
+	})
+
+	c.Run("Too many embeds", func(c *qt.C) {
+		in, _ := json.Marshal(map[string]any{"embeds": make([]any, 11)})
+		_, err := validateDiscord(in)
+		c.Assert(err, qt.ErrorMatches, "message has 11 embeds, Discord allows at most 10")
+	})
+}
+
+func TestValidateTeams(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("Too large", func(c *qt.C) {
+		in, _ := json.Marshal(map[string]any{"text": strings.Repeat("custard ", 4000)})
+		_, err := validateTeams(in)
+		c.Assert(err, qt.ErrorMatches, "message is 32011 bytes, Teams allows at most 28000")
+	})
+
+	c.Run("Mentions listed as entities", func(c *qt.C) {
+		in, _ := json.Marshal(map[string]any{
+			"attachments": []any{map[string]any{
+				"content": map[string]any{
+					"body": []any{
+						map[string]any{"text": "Review requested from <at>jeff@example.com</at>"},
+						map[string]any{"text": "<at>jeff@example.com</at> and <at>toggle@example.com</at>"},
+					},
+				},
+			}},
+		})
+		out, err := validateTeams(in)
+		c.Assert(err, qt.IsNil)
+
+		var msg struct {
+			Attachments []struct {
+				Content struct {
+					MSTeams struct {
+						Entities []map[string]any
+					}
+				}
+			}
+		}
+		c.Assert(json.Unmarshal(out, &msg), qt.IsNil)
+		c.Assert(msg.Attachments[0].Content.MSTeams.Entities, qt.DeepEquals, []map[string]any{
+			{
+				"type":      "mention",
+				"text":      "<at>jeff@example.com</at>",
+				"mentioned": map[string]any{"id": "jeff@example.com", "name": "jeff@example.com"},
+			},
+			{
+				"type":      "mention",
+				"text":      "<at>toggle@example.com</at>",
+				"mentioned": map[string]any{"id": "toggle@example.com", "name": "toggle@example.com"},
+			},
+		})
+	})
+
+	c.Run("Unchanged without mentions", func(c *qt.C) {
+		in := []byte(`{"attachments": [{"content": {"body": []}}]}`)
+		out, err := validateTeams(in)
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(out), qt.Equals, string(in))
+	})
+}
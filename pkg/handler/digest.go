@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spaceweasel/slackhub/pkg/github"
+)
+
+const (
+	defaultDigestWindow = 24 * time.Hour
+	// maxDigestReviews limits how many pull requests reviews are fetched for.
+	maxDigestReviews = 50
+	topReviewers     = 5
+)
+
+type digest struct {
+	// Window is the period covered, e.g. 7 days.
+	Window string
+	Opened []digestPull
+	Merged []digestPull
+	// Closed holds those closed without merging.
+	Closed   []digestPull
+	Releases []github.Release
+	// Reviewers are those who reviewed most, most first.
+	Reviewers []reviewerCount
+	// TimeToFirstReview and TimeToMerge are medians, empty if unknown.
+	TimeToFirstReview string
+	TimeToMerge       string
+	FailedWorkflows   []failedWorkflow
+}
+
+type digestPull struct {
+	Number int
+	Title  string
+	URL    string
+	Author string
+}
+
+type reviewerCount struct {
+	Login string
+	Count int
+}
+
+type failedWorkflow struct {
+	Name string
+	// Count is the number of failed runs, the latest of which is at URL.
+	Count int
+	URL   string
+}
+
+// summariseActivity reports on the activity of a repository in the window up
+// to now.
+func summariseActivity(ctx context.Context, gh GitHub, repo string, now time.Time, window time.Duration) (digest, error) {
+	since := now.Add(-window)
+	d := digest{Window: formatAge(window)}
+
+	pulls, err := gh.PullRequests(ctx, repo, since)
+	if err != nil {
+		return d, fmt.Errorf("could not list pull requests, %w", err)
+	}
+
+	var toReview, toMerge []time.Duration
+	reviews := make(map[string]int)
+	for i, pr := range pulls {
+		p := digestPull{Number: pr.Number, Title: pr.Title, URL: pr.HTMLURL, Author: pr.User.Login}
+		if !pr.CreatedAt.Before(since) {
+			d.Opened = append(d.Opened, p)
+		}
+		switch {
+		case pr.MergedAt != nil && !pr.MergedAt.Before(since):
+			d.Merged = append(d.Merged, p)
+			toMerge = append(toMerge, pr.MergedAt.Sub(pr.CreatedAt))
+		case pr.MergedAt == nil && pr.ClosedAt != nil && !pr.ClosedAt.Before(since):
+			d.Closed = append(d.Closed, p)
+		}
+
+		if i >= maxDigestReviews {
+			continue
+		}
+		rs, err := gh.Reviews(ctx, repo, pr.Number)
+		if err != nil {
+			return d, fmt.Errorf("could not list reviews of #%d, %w", pr.Number, err)
+		}
+		first := true
+		for _, r := range rs {
+			// authors can only comment on their own pull requests
+			if r.User.Login == pr.User.Login || r.State == "PENDING" {
+				continue
+			}
+			if first && !r.SubmittedAt.Before(since) {
+				toReview = append(toReview, r.SubmittedAt.Sub(pr.CreatedAt))
+			}
+			first = false
+			if !r.SubmittedAt.Before(since) {
+				reviews[r.User.Login]++
+			}
+		}
+	}
+	d.TimeToFirstReview = formatMedian(toReview)
+	d.TimeToMerge = formatMedian(toMerge)
+
+	for login, n := range reviews {
+		d.Reviewers = append(d.Reviewers, reviewerCount{Login: login, Count: n})
+	}
+	sort.Slice(d.Reviewers, func(i, j int) bool {
+		a, b := d.Reviewers[i], d.Reviewers[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Login < b.Login
+	})
+	if len(d.Reviewers) > topReviewers {
+		d.Reviewers = d.Reviewers[:topReviewers]
+	}
+
+	if d.Releases, err = gh.Releases(ctx, repo, since); err != nil {
+		return d, fmt.Errorf("could not list releases, %w", err)
+	}
+
+	runs, err := gh.FailedRuns(ctx, repo, since)
+	if err != nil {
+		return d, fmt.Errorf("could not list workflow runs, %w", err)
+	}
+	workflows := make(map[string]int)
+	for _, r := range runs {
+		i, ok := workflows[r.Name]
+		if !ok {
+			// runs are most recent first
+			i = len(d.FailedWorkflows)
+			workflows[r.Name] = i
+			d.FailedWorkflows = append(d.FailedWorkflows, failedWorkflow{Name: r.Name, URL: r.HTMLURL})
+		}
+		d.FailedWorkflows[i].Count++
+	}
+
+	return d, nil
+}
+
+func formatMedian(ds []time.Duration) string {
+	if len(ds) == 0 {
+		return ""
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	m := ds[len(ds)/2]
+	if len(ds)%2 == 0 {
+		m = (ds[len(ds)/2-1] + m) / 2
+	}
+	return formatAge(m)
+}
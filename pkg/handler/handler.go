@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
 	"text/template"
 	"time"
 
+	"github.com/spaceweasel/slackhub/pkg/github"
 	"github.com/spaceweasel/slackhub/pkg/markdown"
+	"github.com/spaceweasel/slackhub/pkg/slack"
+	"github.com/spaceweasel/slackhub/pkg/store"
 )
 
 //go:embed templates
@@ -20,46 +24,694 @@ type Poster interface {
 	Post(ctx context.Context, reader io.Reader) error
 }
 
+// Opener opens direct message conversations with Slack users.
+type Opener interface {
+	OpenConversation(ctx context.Context, user string) (string, error)
+}
+
+// GitHub fetches details missing from event payloads.
+type GitHub interface {
+	LastComment(ctx context.Context, repo string, number, count int) (*github.Comment, error)
+	OpenPullRequests(ctx context.Context, repo string) ([]github.PullRequest, error)
+	PullRequests(ctx context.Context, repo string, since time.Time) ([]github.PullRequest, error)
+	Reviews(ctx context.Context, repo string, number int) ([]github.Review, error)
+	IssueEvents(ctx context.Context, repo string, number int) ([]github.IssueEvent, error)
+	Releases(ctx context.Context, repo string, since time.Time) ([]github.Release, error)
+	FailedRuns(ctx context.Context, repo string, since time.Time) ([]github.WorkflowRun, error)
+	ReviewComments(ctx context.Context, repo string, number int, reviewID int64) ([]github.ReviewComment, error)
+}
+
+// RefPoster is implemented by posters able to return a reference to the
+// posted message.
+type RefPoster interface {
+	PostRef(ctx context.Context, reader io.Reader) (store.Ref, error)
+}
+
+// Updater is implemented by posters able to update a posted message.
+type Updater interface {
+	Update(ctx context.Context, ref store.Ref, reader io.Reader) error
+}
+
+// tagger is implemented by stores which keep refs in the metadata of the
+// messages themselves, replaced when a message is updated.
+type tagger interface {
+	Metadata(keys []string, ttl time.Duration, counted ...store.Counted) store.Metadata
+	Keys(ctx context.Context, ref store.Ref) ([]string, error)
+}
+
+// channelStore is implemented by stores which search the history of a channel,
+// so need another store to find the refs of messages posted elsewhere.
+type channelStore interface {
+	ForChannel(channel string) store.Store
+}
+
+// refTTL is how long refs are stored, covering re-runs of a workflow, which
+// GitHub allows for 30 days.
+const refTTL = 30 * 24 * time.Hour
+
+// Logger is satisfied by the action logger.
+type Logger interface {
+	Debugf(msg string, args ...any)
+	Infof(msg string, args ...any)
+	Warningf(msg string, args ...any)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...any)   {}
+func (nopLogger) Infof(string, ...any)    {}
+func (nopLogger) Warningf(string, ...any) {}
+
 type Handler struct {
-	p Poster
+	p       Poster
+	log     Logger
+	backend Backend
+	users   map[string]string
+	opener  Opener
+	dmOnly  bool
+	gh      GitHub
+	store   store.Store
+	limit   int
+	// stale pull request reminders
+	staleAfter  time.Duration
+	staleIgnore map[string]bool
+	// activity digests
+	digestWindow time.Duration
+	// coalescing bursts of pushes
+	debounce time.Duration
+	// one message for a review and its comments
+	aggregateReviews bool
+	// reactions to pull request root messages
+	reactor   Reactor
+	reactions map[string]string
+	now       func() time.Time
+}
+
+type Option func(*Handler)
+
+// WithUsers maps GitHub logins to chat users, such as Slack user IDs or
+// Mattermost usernames, allowing users to be mentioned.
+func WithUsers(users map[string]string) Option {
+	return func(h *Handler) {
+		h.users = users
+	}
+}
+
+// WithDirectMessages sends review requests and assignments to the mapped Slack
+// user as a direct message, as well as the channel unless only is set.
+func WithDirectMessages(opener Opener, only bool) Option {
+	return func(h *Handler) {
+		h.opener = opener
+		h.dmOnly = only
+	}
+}
+
+// WithGitHub allows details missing from the event, such as the reason a pull
+// request was closed, to be fetched from the GitHub API.
+func WithGitHub(gh GitHub) Option {
+	return func(h *Handler) {
+		h.gh = gh
+	}
+}
+
+// WithLogger logs markdown parsing at debug level, and warns when details
+// can't be fetched.
+func WithLogger(l Logger) Option {
+	return func(h *Handler) {
+		h.log = l
+	}
+}
+
+// WithBackend renders messages for a chat platform other than Slack.
+func WithBackend(b Backend) Option {
+	return func(h *Handler) {
+		h.backend = b
+	}
+}
+
+// WithStore skips messages already posted, such as when a workflow is re-run,
+// and keeps the message announcing each pull request. The message about a
+// deployment is updated with each of its statuses, if the poster is able to.
+func WithStore(s store.Store) Option {
+	return func(h *Handler) {
+		h.store = s
+	}
+}
+
+// WithStaleReminders sets how long a pull request waits on review before it's
+// included in scheduled reminders, ignoring those with any of the labels.
+func WithStaleReminders(after time.Duration, ignoreLabels map[string]bool) Option {
+	return func(h *Handler) {
+		if after > 0 {
+			h.staleAfter = after
+		}
+		h.staleIgnore = ignoreLabels
+	}
+}
+
+// WithDigestWindow sets the period covered by scheduled activity digests.
+func WithDigestWindow(window time.Duration) Option {
+	return func(h *Handler) {
+		if window > 0 {
+			h.digestWindow = window
+		}
+	}
+}
+
+// WithDebounce updates the message about new commits to a pull request, or a
+// push to a branch, rather than posting another while they keep coming within
+// the window. It needs a store and a poster able to update messages.
+func WithDebounce(window time.Duration) Option {
+	return func(h *Handler) {
+		h.debounce = window
+	}
+}
+
+// WithReviewAggregation lists the comments of a review in the message for the
+// review submitted, fetched from the GitHub API, so that the events for each
+// comment can be ignored. Reviews without a body or comments aren't posted.
+func WithReviewAggregation() Option {
+	return func(h *Handler) {
+		h.aggregateReviews = true
+	}
+}
+
+// WithReactions reacts to the root message of a pull request when it's
+// reviewed, merged or closed, rather than posting another, with the emoji
+// mapped from each status, as in DefaultReactions. Root messages are found in
+// the store.
+func WithReactions(r Reactor, reactions map[string]string) Option {
+	return func(h *Handler) {
+		h.reactor = r
+		h.reactions = reactions
+	}
+}
+
+// WithClock sets the current time, e.g. for reproducible reminders.
+func WithClock(now func() time.Time) Option {
+	return func(h *Handler) {
+		h.now = now
+	}
 }
 
-func New(poster Poster) *Handler {
-	return &Handler{
-		p: poster,
+// WithCommitLimit sets the maximum number of commits listed for a push.
+func WithCommitLimit(n int) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.limit = n
+		}
 	}
 }
 
+func New(poster Poster, opts ...Option) *Handler {
+	h := &Handler{
+		p:       poster,
+		log:     nopLogger{},
+		backend: Backends["slack"],
+		limit:   defaultCommitLimit,
+
+		staleAfter:   defaultStaleAfter,
+		digestWindow: defaultDigestWindow,
+		now:          time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
 type EventContext interface {
+	// ID identifies the delivery or workflow run, which is the same when
+	// re-run, or is empty if unknown.
+	ID() string
 	Channel() string
 	Actor() string
 	Name() string
 	Action() string
 	Event() any
 	Branch() string
+	Get(key string) any
+}
+
+// directActions maps the actions that can be sent as direct messages to the
+// event key holding the GitHub login of the recipient.
+var directActions = map[string]string{
+	"pull_request.review_requested":       "requested_reviewer.login",
+	"pull_request.review_request_removed": "requested_reviewer.login",
+	"pull_request.assigned":               "assignee.login",
+	"pull_request.unassigned":             "assignee.login",
 }
 
 func (h *Handler) Handle(ec EventContext) error {
+	name := fmt.Sprintf("%s/%s.tmpl", ec.Name(), ec.Action())
+	if _, err := fs.Stat(h.backend.Templates, name); err != nil {
+		// backends only support some events
+		h.log.Infof("Skipping %s.%s, which %s has no template for", ec.Name(), ec.Action(), h.backend.Name)
+		return nil
+	}
+
+	build, built := h.backend.builders[ec.Name()+"."+ec.Action()]
+
 	tpl, err := template.New("").
 		Delims("««", "»»").
 		Funcs(template.FuncMap{
 			"AsTimestamp":   AsTimestamp,
-			"SlackMarkdown": SlackMarkdown,
+			"JSON":          JSON,
+			"Link":          h.backend.Link,
+			"Milestone":     Milestone,
+			"SlackEscape":   slack.Escape,
+			"SlackMarkdown": h.SlackMarkdown,
+			"SlackUser":     h.User,
 			"ShortSHA":      ShortSHA,
+			"User":          h.User,
 		}).
-		ParseFS(templates, fmt.Sprintf("templates/%s/%s.tmpl", ec.Name(), ec.Action()))
+		ParseFS(h.backend.Templates, name)
 	if err != nil {
 		return fmt.Errorf("could not instantiate template, %w", err)
 	}
+	render := func(msg message) ([]byte, error) {
+		if built {
+			return h.build(build, tpl, msg)
+		}
+		out := bytes.NewBuffer(nil)
+		if err := tpl.ExecuteTemplate(out, ec.Action()+".tmpl", msg); err != nil {
+			return nil, fmt.Errorf("could not execute template, %w", err)
+		}
+		return out.Bytes(), nil
+	}
+
+	ctx := context.Background()
+	details, err := h.details(ctx, ec)
+	if err != nil {
+		return err
+	}
+	if s, ok := details["stale"].(staleSummary); ok && s.Count == 0 {
+		h.log.Infof("No pull requests waiting on review")
+		return nil
+	}
+	if r, ok := details["review"].(reviewSummary); ok && len(r.Comments) == 0 && ec.Get("review.state") != "approved" {
+		if body, _ := ec.Get("review.body").(string); body == "" {
+			h.log.Infof("Skipping review without a body or comments")
+			return nil
+		}
+	}
+
+	if user := h.recipient(ec); user != "" {
+		channel, err := h.opener.OpenConversation(ctx, user)
+		if err != nil {
+			return fmt.Errorf("could not open direct message, %w", err)
+		}
+		if err := h.post(ctx, render, message{channelOverride{ec, channel}, details}); err != nil {
+			return err
+		}
+		if h.dmOnly {
+			return nil
+		}
+	}
+
+	return h.post(ctx, render, message{ec, details})
+}
+
+// message is passed to the templates, exposing the event context along with
+// any details gathered while handling the event.
+type message struct {
+	EventContext
+	Details map[string]any
+}
+
+// details gathers anything the templates need that is missing from the event.
+// Most details are optional, so failing to fetch them doesn't prevent the
+// message from being sent.
+func (h *Handler) details(ctx context.Context, ec EventContext) (map[string]any, error) {
+	d := make(map[string]any)
+
+	switch ec.Name() + "." + ec.Action() {
+	case "schedule.digest":
+		if h.gh == nil {
+			return nil, fmt.Errorf("digests need the GitHub API, set github_token")
+		}
+		repo, _ := ec.Get("repository.full_name").(string)
+		digest, err := summariseActivity(ctx, h.gh, repo, h.now(), h.digestWindow)
+		if err != nil {
+			return nil, err
+		}
+		d["digest"] = digest
+
+	case "schedule.default":
+		if h.gh == nil {
+			return nil, fmt.Errorf("reminders need the GitHub API, set github_token")
+		}
+		repo, _ := ec.Get("repository.full_name").(string)
+		org, _ := ec.Get("repository.owner.login").(string)
+		stale, err := summariseStale(ctx, h.gh, repo, org, h.now(), h.staleAfter, h.staleIgnore)
+		if err != nil {
+			return nil, err
+		}
+		d["stale"] = stale
+
+	case "push.default":
+		d["push"] = summarisePush(ec, h.limit)
+
+	case "pull_request.synchronize":
+		d["updates"] = 1
+
+	case "pull_request_review_comment.created":
+		comment, err := eventComment(ec)
+		if err != nil {
+			h.log.Warningf("could not read the review comment, %v", err)
+			break
+		}
+		url, _ := ec.Get("pull_request.html_url").(string)
+		if s, ok := reviewSnippet(comment, url); ok {
+			d["snippet"] = s
+		}
+		d["original"] = originalCode(comment)
+
+	case "pull_request_review.submitted":
+		if !h.aggregateReviews {
+			break
+		}
+		if h.gh == nil {
+			return nil, fmt.Errorf("aggregating reviews needs the GitHub API, set github_token")
+		}
+		review, err := summariseReview(ctx, h.gh, ec)
+		if err != nil {
+			return nil, fmt.Errorf("could not list review comments, %w", err)
+		}
+		d["review"] = review
+
+	case "pull_request.closed":
+		if h.gh == nil || ec.Get("pull_request.merged") == true {
+			break
+		}
+		repo, _ := ec.Get("repository.full_name").(string)
+		number, _ := ec.Get("pull_request.number").(float64)
+		count, _ := ec.Get("pull_request.comments").(float64)
+		comment, err := h.gh.LastComment(ctx, repo, int(number), int(count))
+		if err != nil {
+			h.log.Warningf("could not fetch the reason for closing, %v", err)
+			break
+		}
+		if comment == nil {
+			break
+		}
+		// only the closer's comment explains why it was closed
+		if comment.User.Login == ec.Actor() {
+			d["reason"] = comment.Body
+		}
+	}
+
+	return d, nil
+}
+
+func (h *Handler) post(ctx context.Context, render func(message) ([]byte, error), msg message) error {
+	var keys []string
+	s := h.storeFor(msg.Channel())
+	if s != nil {
+		if key := dedupeKey(msg); key != "" {
+			_, ok, err := s.Get(ctx, key)
+			if err != nil {
+				h.log.Warningf("could not check whether already posted, %v", err)
+			}
+			if ok {
+				h.log.Infof("Skipping message already posted: %s", key)
+				return nil
+			}
+			keys = append(keys, key)
+		}
+		// the first message about a pull request in the channel is its root
+		if _, dm := msg.EventContext.(channelOverride); !dm && msg.Name() == "pull_request" {
+			if key := rootKey(msg); key != "" {
+				if _, ok, err := s.Get(ctx, key); err == nil && !ok {
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+
+	var (
+		prev   store.Ref
+		update bool
+		count  int
+	)
+	if key := h.deploymentKey(msg); key != "" {
+		var err error
+		if prev, update, err = s.Get(ctx, key); err != nil {
+			h.log.Warningf("could not find the message to update, %v", err)
+		}
+		keys = append(keys, key)
+	}
+	debounce := h.debounceKey(msg)
+	if debounce != "" {
+		var err error
+		if prev, update, err = s.Get(ctx, debounce); err != nil {
+			h.log.Warningf("could not find the message to update, %v", err)
+		}
+		count = coalesce(msg.Details, prev.Count)
+	}
+	t, tagged := s.(tagger)
+	// metadata holds the keys of a message, and any burst it coalesces
+	metadata := func() store.Metadata {
+		var counted []store.Counted
+		if debounce != "" {
+			counted = append(counted, store.Counted{Key: debounce, Count: count, TTL: h.debounce})
+		}
+		return t.Metadata(keys, refTTL, counted...)
+	}
+
+	out, err := render(msg)
+	if err != nil {
+		return err
+	}
+
+	payload, err := h.backend.Validate(out)
+	if err != nil {
+		return fmt.Errorf("invalid message, %w", err)
+	}
+
+	if update {
+		h.log.Infof("Updating message: %s", prev.TS)
+		// the metadata of the message is replaced along with it
+		if tagged {
+			earlier, err := t.Keys(ctx, prev)
+			if err != nil {
+				h.log.Warningf("could not find the keys of the message to update, %v", err)
+			}
+			keys = union(earlier, keys)
+			if payload, err = withMetadata(payload, metadata()); err != nil {
+				return err
+			}
+		}
+		if err := h.p.(Updater).Update(ctx, prev, bytes.NewReader(payload)); err != nil {
+			return fmt.Errorf("could not update message, %w", err)
+		}
+		h.put(ctx, keys, prev, refTTL)
+		if debounce != "" {
+			prev.Count = count
+			h.put(ctx, []string{debounce}, prev, h.debounce)
+		}
+		return nil
+	}
+
+	if len(keys) == 0 && debounce == "" {
+		return h.p.Post(ctx, bytes.NewReader(payload))
+	}
+
+	if tagged {
+		if payload, err = withMetadata(payload, metadata()); err != nil {
+			return err
+		}
+	}
+
+	ref := store.Ref{Channel: msg.Channel()}
+	if rp, ok := h.p.(RefPoster); ok {
+		ref, err = rp.PostRef(ctx, bytes.NewReader(payload))
+	} else {
+		err = h.p.Post(ctx, bytes.NewReader(payload))
+	}
+	if err != nil {
+		return err
+	}
+
+	h.put(ctx, keys, ref, refTTL)
+	if debounce != "" {
+		ref.Count = count
+		h.put(ctx, []string{debounce}, ref, h.debounce)
+	}
+
+	return nil
+}
+
+// union returns the keys in either a or b, in order, without repeats.
+func union(a, b []string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, k := range append(append([]string(nil), a...), b...) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// storeFor returns the store keeping the refs of messages posted to a channel.
+func (h *Handler) storeFor(channel string) store.Store {
+	if cs, ok := h.store.(channelStore); ok {
+		return cs.ForChannel(channel)
+	}
+	return h.store
+}
+
+// put stores a ref for each key. Failing to store only risks posting again, so
+// is logged rather than returned.
+func (h *Handler) put(ctx context.Context, keys []string, ref store.Ref, ttl time.Duration) {
+	for _, key := range keys {
+		if err := h.store.Put(ctx, key, ref, ttl); err != nil {
+			h.log.Warningf("could not store %s, %v", key, err)
+		}
+	}
+}
+
+// debounceKey identifies the message a burst of events is coalesced into,
+// empty if the event isn't debounced.
+func (h *Handler) debounceKey(msg message) string {
+	if h.debounce <= 0 || h.store == nil {
+		return ""
+	}
+	if _, ok := h.p.(Updater); !ok {
+		return ""
+	}
+	if _, ok := h.p.(RefPoster); !ok {
+		return ""
+	}
+	if _, dm := msg.EventContext.(channelOverride); dm {
+		return ""
+	}
+
+	repo, _ := msg.Get("repository.full_name").(string)
+	switch msg.Name() + "." + msg.Action() {
+	case "pull_request.synchronize":
+		number, _ := msg.Get("pull_request.number").(float64)
+		return fmt.Sprintf("debounce/pull_request/%s#%d/%s", repo, int(number), msg.Channel())
+	case "push.default":
+		// new, deleted and force-pushed branches are worth a message of their own
+		for _, k := range []string{"created", "deleted", "forced"} {
+			if msg.Get(k) == true {
+				return ""
+			}
+		}
+		return fmt.Sprintf("debounce/push/%s/%s/%s", repo, msg.Branch(), msg.Channel())
+	}
+	return ""
+}
+
+// deploymentKey identifies the message about a deployment, which is updated
+// with each of its statuses rather than posting another, empty if the event
+// isn't about a deployment or the message can't be updated.
+func (h *Handler) deploymentKey(msg message) string {
+	if h.store == nil {
+		return ""
+	}
+	if _, ok := h.p.(Updater); !ok {
+		return ""
+	}
+	if _, ok := h.p.(RefPoster); !ok {
+		return ""
+	}
+	if msg.Name() != "deployment" && msg.Name() != "deployment_status" {
+		return ""
+	}
+	id, ok := msg.Get("deployment.id").(float64)
+	if !ok {
+		return ""
+	}
+	repo, _ := msg.Get("repository.full_name").(string)
+	return fmt.Sprintf("deployment/%s/%d/%s", repo, int64(id), msg.Channel())
+}
+
+// coalesce adds the commits, or updates, of the message being updated to
+// those of the event, returning the total.
+func coalesce(details map[string]any, prev int) int {
+	if p, ok := details["push"].(pushSummary); ok {
+		p.Total += prev
+		details["push"] = p
+		return p.Total
+	}
+	n, _ := details["updates"].(int)
+	details["updates"] = n + prev
+	return n + prev
+}
+
+// dedupeKey identifies a message posted for an event, so it isn't posted
+// again when a workflow is re-run or a delivery retried.
+func dedupeKey(msg message) string {
+	if msg.ID() == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s/%s/%s", msg.Name(), msg.Action(), msg.ID(), msg.Channel())
+}
+
+// rootKey identifies the message announcing the pull request an event is
+// about in a channel, empty if it isn't about one.
+func rootKey(ec EventContext) string {
+	number, ok := ec.Get("pull_request.number").(float64)
+	if !ok {
+		return ""
+	}
+	repo, _ := ec.Get("repository.full_name").(string)
+	return fmt.Sprintf("pull_request/%s#%d/%s", repo, int(number), ec.Channel())
+}
+
+// withMetadata adds Slack message metadata to a message.
+func withMetadata(payload []byte, md store.Metadata) ([]byte, error) {
+	var msg map[string]any
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return nil, err
+	}
+	msg["metadata"] = md
 
 	out := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(msg); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
 
-	if err := tpl.ExecuteTemplate(out, ec.Action()+".tmpl", ec); err != nil {
-		return fmt.Errorf("could not execute template, %w", err)
+// recipient returns the Slack user to send a direct message to, if any.
+func (h *Handler) recipient(ec EventContext) string {
+	if h.opener == nil {
+		return ""
 	}
+	key, ok := directActions[ec.Name()+"."+ec.Action()]
+	if !ok {
+		return ""
+	}
+	login, _ := ec.Get(key).(string)
+	return h.users[login]
+}
 
-	ctx := context.Background()
-	return h.p.Post(ctx, out)
+// channelOverride replaces the channel of an event context.
+type channelOverride struct {
+	EventContext
+	channel string
+}
+
+func (c channelOverride) Channel() string {
+	return c.channel
+}
+
+// User returns a mention for the chat user mapped to a GitHub login, falling
+// back to a link to the GitHub profile.
+func (h *Handler) User(login string) string {
+	if id, ok := h.users[login]; ok {
+		return h.backend.Mention(id)
+	}
+	return h.backend.Link("https://github.com/"+login, login)
 }
 
 func AsTimestamp(s string) int64 {
@@ -70,19 +722,44 @@ func AsTimestamp(s string) int64 {
 	return ts.Unix()
 }
 
-func SlackMarkdown(v any) string {
+// SlackMarkdown converts GitHub markdown to Slack mrkdwn. The code a review
+// comment is on can be given, for any changes it suggests.
+func (h *Handler) SlackMarkdown(v any, original ...any) string {
 	s, ok := v.(string)
 	if !ok {
 		return ""
 	}
-	md, err := markdown.Parse(s)
+	opts := []markdown.Option{markdown.WithLogger(h.log)}
+	if len(original) > 0 {
+		if code, ok := original[0].(string); ok {
+			opts = append(opts, markdown.WithOriginal(code))
+		}
+	}
+	md, err := markdown.Text(s, opts...)
 	if err != nil {
-		log.Println(">>SlackMarkdown:", err)
+		h.log.Warningf("could not convert markdown, %v", err)
 		return ""
 	}
 	return md
 }
 
+// Milestone reports whether a count, such as stars or forks, is worth celebrating.
+func Milestone(v any) bool {
+	n, ok := v.(float64)
+	if !ok || n <= 0 {
+		return false
+	}
+	if n >= 1000 {
+		return int64(n)%1000 == 0
+	}
+	for _, m := range []float64{10, 25, 50, 100, 250, 500} {
+		if n == m {
+			return true
+		}
+	}
+	return false
+}
+
 func ShortSHA(s string) string {
 	if len(s) > 8 {
 		return s[:8]
@@ -1,42 +1,1096 @@
 package handler_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	qt "github.com/frankban/quicktest"
 
+	"github.com/spaceweasel/slackhub/pkg/github"
 	"github.com/spaceweasel/slackhub/pkg/handler"
+	"github.com/spaceweasel/slackhub/pkg/store"
 )
 
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestHandler_Handle renders every fixture in testdata for each backend,
+// comparing the message with the golden file of the same name in
+// testdata/golden/<backend>, and checks each embedded template has at least
+// one fixture. Run with -update to accept changes to the output.
 func TestHandler_Handle(t *testing.T) {
 	c := qt.New(t)
 
-	poster := &MockPoster{}
-	h := handler.New(poster)
+	fixtures, err := filepath.Glob("testdata/*.json")
+	c.Assert(err, qt.IsNil)
+
+	type test struct {
+		fixture   string
+		eventName string
+	}
+	var tests []test
+	for _, fixture := range fixtures {
+		fixture = strings.TrimSuffix(filepath.Base(fixture), ".json")
+		eventName, _, _ := strings.Cut(fixture, ".")
+		tests = append(tests, test{fixture: fixture, eventName: eventName})
+	}
+
+	// mattermost uses the slack templates
+	for _, name := range []string{"slack", "teams", "discord"} {
+		backend := handler.Backends[name]
+		covered := make(map[string]bool)
+		for _, tt := range tests {
+			ec := createContext(c, "biscuits", "jeff", tt.eventName, tt.fixture)
+			tmpl := path.Join(tt.eventName, ec.Action()+".tmpl")
+			if _, err := fs.Stat(backend.Templates, tmpl); err != nil {
+				continue
+			}
+
+			c.Run(name+"/"+tt.fixture, func(c *qt.C) {
+				var payload []byte
+				poster := &MockPoster{
+					PostFn: func(ctx context.Context, reader io.Reader) (err error) {
+						payload, err = io.ReadAll(reader)
+						return err
+					},
+				}
 
-	//ctx:= context.TODO()
+				h := handler.New(poster,
+					handler.WithBackend(backend),
+					handler.WithGitHub(recordedGitHub(c)),
+					handler.WithStaleReminders(0, map[string]bool{"on hold": true}),
+					handler.WithReviewAggregation(),
+					handler.WithClock(func() time.Time { return now }),
+				)
+				err := h.Handle(ec)
+				c.Assert(err, qt.IsNil)
+				covered[tmpl] = true
 
-	ec := createContext(c, "biscuits", "jeff", "pull_request")
+				c.Assert(string(payload), qt.Not(qt.Contains), "<no value>")
+				got := normalise(c, payload)
 
-	poster.PostFn = func(ctx context.Context, reader io.Reader) error {
-		b, err := io.ReadAll(reader)
+				golden := filepath.Join("testdata", "golden", name, tt.fixture+".json")
+				if *update {
+					err := os.WriteFile(golden, got, 0o644)
+					c.Assert(err, qt.IsNil)
+				}
+				want, err := os.ReadFile(golden)
+				c.Assert(err, qt.IsNil, qt.Commentf("run with -update to create the golden file"))
+				c.Assert(string(got), qt.Equals, string(want))
+			})
+		}
+
+		templates, err := fs.Glob(backend.Templates, "*/*.tmpl")
 		c.Assert(err, qt.IsNil)
-		c.Logf("%s", string(b))
-		c.Assert(b, qt.HasLen, 3)
-		return nil
+		for _, tmpl := range templates {
+			c.Check(covered[tmpl], qt.IsTrue, qt.Commentf("no fixture for %s/%s", name, tmpl))
+		}
+	}
+}
+
+// normalise indents the message with sorted keys, replacing timestamps of the
+// current time, which templates use when the event has none.
+func normalise(c *qt.C, payload []byte) []byte {
+	var msg any
+	err := json.Unmarshal(payload, &msg)
+	c.Assert(err, qt.IsNil, qt.Commentf("%s", payload))
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, e := range v {
+				if ts, ok := e.(float64); ok && k == "ts" && math.Abs(float64(time.Now().Unix())-ts) < 60 {
+					v[k] = "<now>"
+					continue
+				}
+				walk(e)
+			}
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(msg)
+
+	out := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(msg)
+	c.Assert(err, qt.IsNil)
+	return out.Bytes()
+}
+
+func TestHandler_HandleEvents(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		name      string
+		eventName string
+		fixture   string
+		want      []string
+	}{
+		{
+			name:      "Deployment created",
+			eventName: "deployment",
+			fixture:   "deployment",
+			want: []string{
+				"Deployment to `production` created by <https://github.com/jeff|jeff>",
+				"/commit/9f3c2b7d41a8e6f0c5d2b1a4e7f8c9d0a1b2c3d4|`9f3c2b7d`>",
+			},
+		},
+		{
+			name:      "Deployment status success",
+			eventName: "deployment_status",
+			fixture:   "deployment_status",
+			want: []string{
+				"Deployment to `production` succeeded",
+				`"title_link":"https://jeff-test.example.com"`,
+				"#36a64f",
+			},
+		},
+		{
+			name:      "Branch created",
+			eventName: "create",
+			fixture:   "create",
+			want: []string{
+				"New branch <https://github.com/spaceweasel/jeff-test/tree/release/1.2|`release/1.2`> created by",
+			},
+		},
+		{
+			name:      "Tag deleted",
+			eventName: "delete",
+			fixture:   "delete",
+			want: []string{
+				"Tag `v0.9.0` deleted by",
+			},
+		},
+		{
+			name:      "Forked at a milestone",
+			eventName: "fork",
+			fixture:   "fork",
+			want: []string{
+				":tada: <https://github.com/togglebuild/jeff-test|togglebuild/jeff-test> forked by",
+				"100 forks",
+			},
+		},
+		{
+			name:      "Starred",
+			eventName: "star",
+			fixture:   "star",
+			want: []string{
+				":star: Starred by <https://github.com/jeff|jeff> - 42 stars",
+			},
+		},
+		{
+			name:      "Watched",
+			eventName: "watch",
+			fixture:   "watch",
+			want: []string{
+				":eyes: Watched by",
+			},
+		},
+		{
+			name:      "Discussion created",
+			eventName: "discussion",
+			fixture:   "discussion.created",
+			want: []string{
+				":pray: New discussion in *Q&amp;A* started by",
+				"Is there a way to *skip* notifications for `dependabot` branches?",
+			},
+		},
+		{
+			name:      "Discussion answered",
+			eventName: "discussion",
+			fixture:   "discussion.answered",
+			want: []string{
+				"by <https://github.com/jeff|jeff> marked as answer by",
+				"Set `skip_bots: true`",
+			},
+		},
+		{
+			name:      "Discussion category changed",
+			eventName: "discussion",
+			fixture:   "discussion.category_changed",
+			want: []string{
+				"Discussion moved from :pray: *Q&amp;A* to :bulb: *Ideas*",
+			},
+		},
+		{
+			name:      "Discussion comment created",
+			eventName: "discussion_comment",
+			fixture:   "discussion_comment",
+			want: []string{
+				":pray: Discussion <https://github.com/spaceweasel/jeff-test/discussions/7#discussioncomment-3581207|comment> from",
+			},
+		},
+		{
+			name:      "Review requested from user",
+			eventName: "pull_request",
+			fixture:   "pull_request.review_requested",
+			want: []string{
+				"Review requested from <https://github.com/togglebuild|togglebuild> by",
+			},
+		},
+		{
+			name:      "Review request removed from team",
+			eventName: "pull_request",
+			fixture:   "pull_request.review_request_removed",
+			want: []string{
+				"Review request for <https://github.com/orgs/spaceweasel/teams/back-end-owner|@spaceweasel/back-end-owner> removed by",
+			},
+		},
+		{
+			name:      "Assigned",
+			eventName: "pull_request",
+			fixture:   "pull_request.assigned",
+			want: []string{
+				"Pull request assigned to <https://github.com/togglebuild|togglebuild> by",
+			},
+		},
+		{
+			name:      "Unassigned",
+			eventName: "pull_request",
+			fixture:   "pull_request.unassigned",
+			want: []string{
+				"Pull request unassigned from <https://github.com/togglebuild|togglebuild> by",
+			},
+		},
+		{
+			name:      "Labeled",
+			eventName: "pull_request",
+			fixture:   "pull_request.labeled",
+			want: []string{
+				`"color":"#d73a4a"`,
+				"Label `bug` added by",
+			},
+		},
+		{
+			name:      "Unlabeled",
+			eventName: "pull_request",
+			fixture:   "pull_request.unlabeled",
+			want: []string{
+				"Label `bug` removed by",
+			},
+		},
+		{
+			name:      "Edited",
+			eventName: "pull_request",
+			fixture:   "pull_request.edited",
+			want: []string{
+				"~Another PR Tets~ → Another PR Test",
+				`"value":"updated"`,
+			},
+		},
+		{
+			name:      "Converted to draft",
+			eventName: "pull_request",
+			fixture:   "pull_request.converted_to_draft",
+			want: []string{
+				"Pull request converted to draft by",
+			},
+		},
+		{
+			name:      "Auto-merge enabled",
+			eventName: "pull_request",
+			fixture:   "pull_request.auto_merge_enabled",
+			want: []string{
+				"Auto-merge enabled by",
+				`"value":"squash"`,
+			},
+		},
+		{
+			name:      "Auto-merge disabled",
+			eventName: "pull_request",
+			fixture:   "pull_request.auto_merge_disabled",
+			want: []string{
+				"Auto-merge disabled by",
+				`"text":"Pull request was closed"`,
+			},
+		},
+		{
+			name:      "Locked",
+			eventName: "pull_request",
+			fixture:   "pull_request.locked",
+			want: []string{
+				":lock: Conversation locked as _too heated_ by",
+			},
+		},
+		{
+			name:      "Milestoned",
+			eventName: "pull_request",
+			fixture:   "pull_request.milestoned",
+			want: []string{
+				"added to milestone <https://github.com/spaceweasel/jeff-test/milestone/1|v1.0>",
+				"<!date^1664521200^{date_short}|2022-09-30T07:00:00Z>",
+			},
+		},
+		{
+			name:      "Merged",
+			eventName: "pull_request",
+			fixture:   "pull_request.closed",
+			want: []string{
+				"Pull request merged by",
+				"/commit/4c0d1f2e3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d|`4c0d1f2e`>",
+				"+42 -7 in 3 files",
+			},
+		},
+		{
+			name:      "Closed without merging",
+			eventName: "pull_request",
+			fixture:   "pull_request.closed_unmerged",
+			want: []string{
+				"Pull request closed without merging by",
+				`"color":"#959da5"`,
+			},
+		},
+		{
+			name:      "Push by several authors",
+			eventName: "push",
+			fixture:   "push",
+			want: []string{
+				"|12 new commits> pushed to <https://github.com/spaceweasel/jeff-test/tree/main|`main`> by",
+				`*jeff*\n<https://github.com/spaceweasel/jeff-test/commit/00a1f3c9e7b5d2e4f6a8c0b2d4e6f8a0c2e4f6a8|` + "`00a1f3c9`> - Add custard eater" + `\n`,
+				`*togglebuild*\n`,
+				"|and 2 more>",
+			},
+		},
+		{
+			name:      "Force push",
+			eventName: "push",
+			fixture:   "push.forced",
+			want: []string{
+				":warning: <https://github.com/spaceweasel/jeff-test/compare/bbbbbbbbbbbb...00a1f3c9e7b5|Force-pushed> to",
+				"`bbbbbbbb` → `00a1f3c9`",
+			},
+		},
+		{
+			name:      "New branch push",
+			eventName: "push",
+			fixture:   "push.created",
+			want: []string{
+				"New branch <https://github.com/spaceweasel/jeff-test/tree/feature/spoons|`feature/spoons`> pushed with <https://github.com/spaceweasel/jeff-test/compare/feature/spoons|2 commits> by",
+			},
+		},
+		{
+			name:      "Branch deleted by push",
+			eventName: "push",
+			fixture:   "push.deleted",
+			want: []string{
+				"Branch `feature/spoons` deleted by",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			var payload []byte
+			poster := &MockPoster{
+				PostFn: func(ctx context.Context, reader io.Reader) (err error) {
+					payload, err = io.ReadAll(reader)
+					return err
+				},
+			}
+
+			ec := createContext(c, "biscuits", "jeff", tt.eventName, tt.fixture)
+			err := handler.New(poster).Handle(ec)
+			c.Assert(err, qt.IsNil)
+			c.Assert(json.Valid(payload), qt.IsTrue, qt.Commentf("%s", payload))
+			for _, want := range tt.want {
+				c.Assert(string(payload), qt.Contains, want)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleDirectMessages(t *testing.T) {
+	c := qt.New(t)
+
+	users := map[string]string{"togglebuild": "U0123"}
+
+	tests := []struct {
+		name    string
+		fixture string
+		only    bool
+		want    []string
+	}{
+		{
+			name:    "Direct message as well as channel",
+			fixture: "pull_request.review_requested",
+			want:    []string{"D0123", "biscuits"},
+		},
+		{
+			name:    "Direct message only",
+			fixture: "pull_request.assigned",
+			only:    true,
+			want:    []string{"D0123"},
+		},
+		{
+			name:    "Team requests stay in channel",
+			fixture: "pull_request.review_request_removed",
+			only:    true,
+			want:    []string{"biscuits"},
+		},
+	}
+
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			var channels []string
+			poster := &MockPoster{
+				PostFn: func(ctx context.Context, reader io.Reader) error {
+					var msg struct{ Channel string }
+					if err := json.NewDecoder(reader).Decode(&msg); err != nil {
+						return err
+					}
+					channels = append(channels, msg.Channel)
+					return nil
+				},
+			}
+			opener := openerFunc(func(ctx context.Context, user string) (string, error) {
+				return "D" + user[1:], nil
+			})
+
+			h := handler.New(poster, handler.WithUsers(users), handler.WithDirectMessages(opener, tt.only))
+			err := h.Handle(createContext(c, "biscuits", "jeff", "pull_request", tt.fixture))
+			c.Assert(err, qt.IsNil)
+			c.Assert(channels, qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func TestHandler_HandleStore(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("Re-run not posted again", func(c *qt.C) {
+		posted := 0
+		poster := &MockPoster{
+			PostFn: func(ctx context.Context, reader io.Reader) error {
+				posted++
+				return nil
+			},
+		}
+		s := store.NewMemory()
+		h := handler.New(poster, handler.WithStore(s))
+
+		ec := createContext(c, "biscuits", "jeff", "pull_request", "pull_request.reopened")
+		ec.id = "3035785532"
+		c.Assert(h.Handle(ec), qt.IsNil)
+		c.Assert(h.Handle(ec), qt.IsNil)
+		c.Assert(posted, qt.Equals, 1)
+
+		// the first message about a pull request is its root
+		ref, ok, err := s.Get(context.Background(), "pull_request/spaceweasel/jeff-test#14/biscuits")
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(ref, qt.Equals, store.Ref{Channel: "biscuits"})
+
+		// a different run is posted
+		ec.id = "3035785533"
+		c.Assert(h.Handle(ec), qt.IsNil)
+		c.Assert(posted, qt.Equals, 2)
+	})
+
+	c.Run("Refs tagged in Slack metadata", func(c *qt.C) {
+		var payload map[string]any
+		poster := &MockPoster{
+			PostFn: func(ctx context.Context, reader io.Reader) error {
+				return json.NewDecoder(reader).Decode(&payload)
+			},
+		}
+		var history historyFunc = func(ctx context.Context, channel string, since time.Time) ([]store.Message, error) {
+			return nil, nil
+		}
+		h := handler.New(poster, handler.WithStore(store.NewSlack(history, "biscuits", time.Hour)))
+
+		ec := createContext(c, "biscuits", "jeff", "pull_request", "pull_request.reopened")
+		ec.id = "3035785532"
+		c.Assert(h.Handle(ec), qt.IsNil)
+
+		md, _ := payload["metadata"].(map[string]any)
+		c.Assert(md["event_type"], qt.Equals, "slackhub_ref")
+		c.Assert(md["event_payload"].(map[string]any)["keys"], qt.DeepEquals, []any{
+			"pull_request.reopened/3035785532/biscuits",
+			"pull_request/spaceweasel/jeff-test#14/biscuits",
+		})
+	})
+
+	c.Run("Direct messages found in their own history", func(c *qt.C) {
+		// the messages posted to each channel, with their metadata
+		history := make(map[string][]store.Message)
+		poster := &MockPoster{
+			PostFn: func(ctx context.Context, reader io.Reader) error {
+				var msg struct {
+					Channel  string
+					Metadata store.Metadata
+				}
+				if err := json.NewDecoder(reader).Decode(&msg); err != nil {
+					return err
+				}
+				history[msg.Channel] = append(history[msg.Channel], store.Message{TS: "1662454800.000100", Metadata: msg.Metadata})
+				return nil
+			},
+		}
+		var h historyFunc = func(ctx context.Context, channel string, since time.Time) ([]store.Message, error) {
+			return history[channel], nil
+		}
+		opener := openerFunc(func(ctx context.Context, user string) (string, error) {
+			return "D0123", nil
+		})
+		hdlr := handler.New(poster,
+			handler.WithStore(store.NewSlack(h, "C0456", time.Hour)),
+			handler.WithUsers(map[string]string{"togglebuild": "U0123"}),
+			handler.WithDirectMessages(opener, false),
+		)
+
+		ec := createContext(c, "C0456", "jeff", "pull_request", "pull_request.review_requested")
+		ec.id = "3035785532"
+		c.Assert(hdlr.Handle(ec), qt.IsNil)
+		c.Assert(hdlr.Handle(ec), qt.IsNil)
+		c.Assert(history["D0123"], qt.HasLen, 1)
+		c.Assert(history["C0456"], qt.HasLen, 1)
+	})
+}
+
+func TestHandler_HandleDebounce(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		name      string
+		eventName string
+		fixture   string
+		want      string
+	}{
+		{
+			name:      "Pushes",
+			eventName: "push",
+			fixture:   "push",
+			want:      "|24 new commits> pushed to",
+		},
+		{
+			name:      "Pull request updates",
+			eventName: "pull_request",
+			fixture:   "pull_request.synchronize",
+			want:      "Pull request updated 2 times by",
+		},
+	}
+
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			poster := &MockUpdater{}
+			h := handler.New(poster,
+				handler.WithStore(store.NewMemory()),
+				handler.WithDebounce(time.Hour),
+			)
+
+			ec := createContext(c, "biscuits", "jeff", tt.eventName, tt.fixture)
+			ec.id = "3035785532"
+			c.Assert(h.Handle(ec), qt.IsNil)
+			// a re-run isn't counted twice
+			c.Assert(h.Handle(ec), qt.IsNil)
+			ec.id = "3035785533"
+			c.Assert(h.Handle(ec), qt.IsNil)
+
+			c.Assert(poster.posted, qt.HasLen, 1)
+			c.Assert(poster.updated, qt.HasLen, 1)
+			c.Assert(poster.updated[0], qt.Contains, tt.want)
+		})
+	}
+
+	c.Run("Slack store", func(c *qt.C) {
+		ch := &slackChannel{}
+		h := handler.New(ch,
+			handler.WithStore(store.NewSlack(ch, "C0123", time.Hour)),
+			handler.WithDebounce(time.Hour),
+		)
+
+		ec := createContext(c, "C0123", "jeff", "push", "push")
+		ec.id = "3035785532"
+		c.Assert(h.Handle(ec), qt.IsNil)
+		ec.id = "3035785533"
+		c.Assert(h.Handle(ec), qt.IsNil)
+		// re-running the first is still skipped once its message is updated
+		ec.id = "3035785532"
+		c.Assert(h.Handle(ec), qt.IsNil)
+		ec.id = "3035785534"
+		c.Assert(h.Handle(ec), qt.IsNil)
+
+		c.Assert(ch.msgs, qt.HasLen, 1)
+		c.Assert(ch.updates, qt.Equals, 2)
+		c.Assert(ch.text, qt.Contains, "|36 new commits> pushed to")
+	})
+
+	c.Run("Not without a store", func(c *qt.C) {
+		poster := &MockUpdater{}
+		h := handler.New(poster, handler.WithDebounce(time.Hour))
+
+		ec := createContext(c, "biscuits", "jeff", "push", "push")
+		c.Assert(h.Handle(ec), qt.IsNil)
+		c.Assert(h.Handle(ec), qt.IsNil)
+		c.Assert(poster.posted, qt.HasLen, 2)
+	})
+}
+
+func TestHandler_HandleDeployment(t *testing.T) {
+	c := qt.New(t)
+
+	poster := &MockUpdater{}
+	h := handler.New(poster, handler.WithStore(store.NewMemory()))
+
+	ec := createContext(c, "biscuits", "jeff", "deployment", "deployment")
+	ec.id = "3035785532"
+	c.Assert(h.Handle(ec), qt.IsNil)
+
+	// each status updates the message about the deployment
+	ec = createContext(c, "biscuits", "jeff", "deployment_status", "deployment_status")
+	ec.id = "3035785533"
+	status := ec.event.(map[string]any)["deployment_status"].(map[string]any)
+	status["description"] = `Deployed "main" to C:\prod`
+	c.Assert(h.Handle(ec), qt.IsNil)
+	status["state"] = "failure"
+	ec.id = "3035785534"
+	c.Assert(h.Handle(ec), qt.IsNil)
+
+	c.Assert(poster.posted, qt.HasLen, 1)
+	c.Assert(poster.updated, qt.HasLen, 2)
+	for _, payload := range poster.updated {
+		c.Assert(json.Valid([]byte(payload)), qt.IsTrue, qt.Commentf("%s", payload))
+	}
+	c.Assert(poster.updated[0], qt.Contains, "succeeded")
+	c.Assert(poster.updated[1], qt.Contains, "failed")
+}
+
+func TestHandler_HandleBuiltMessage(t *testing.T) {
+	c := qt.New(t)
+
+	var msg map[string]any
+	poster := &MockPoster{
+		PostFn: func(ctx context.Context, reader io.Reader) error {
+			return json.NewDecoder(reader).Decode(&msg)
+		},
+	}
+	ec := createContext(c, "biscuits", "jeff", "pull_request", "pull_request.reopened")
+	pr := ec.event.(map[string]any)["pull_request"].(map[string]any)
+	pr["title"] = `Rename "Eater" to C:\Consumer`
+	pr["body"] = "Eats \"custard\"\n\tslowly"
+
+	c.Assert(handler.New(poster).Handle(ec), qt.IsNil)
+	att := msg["attachments"].([]any)[0].(map[string]any)
+	c.Assert(att["title"], qt.Equals, `Rename "Eater" to C:\Consumer`)
+	c.Assert(att["fields"].([]any)[0].(map[string]any)["value"], qt.Equals, "Eats \"custard\"\n\tslowly")
+}
+
+// TestHandler_HandleQuotes checks text from the event is escaped, so that
+// quotes and backslashes can't make the message invalid.
+func TestHandler_HandleQuotes(t *testing.T) {
+	c := qt.New(t)
+
+	const text = `Say "hi" to C:\jeff`
+	// markdown loses what follows a backslash
+	const markdown = `Say "hi" to <jeff> & co`
+	tests := []struct {
+		eventName string
+		fixture   string
+		key       string
+		text      string
+	}{
+		{eventName: "create", fixture: "create", key: "ref"},
+		{eventName: "delete", fixture: "delete", key: "ref"},
+		{eventName: "fork", fixture: "fork", key: "forkee.full_name"},
+		{eventName: "discussion", fixture: "discussion.created", key: "discussion.title"},
+		{eventName: "discussion", fixture: "discussion.created", key: "discussion.category.name"},
+		{eventName: "discussion", fixture: "discussion.answered", key: "discussion.title"},
+		{eventName: "discussion", fixture: "discussion.category_changed", key: "changes.category.from.name"},
+		{eventName: "discussion_comment", fixture: "discussion_comment", key: "discussion.title"},
+		{eventName: "pull_request", fixture: "pull_request.review_requested", key: "pull_request.title"},
+		{eventName: "pull_request", fixture: "pull_request.review_request_removed", key: "requested_team.slug"},
+		{eventName: "pull_request", fixture: "pull_request.assigned", key: "pull_request.title"},
+		{eventName: "pull_request", fixture: "pull_request.unassigned", key: "pull_request.title"},
+		{eventName: "pull_request", fixture: "pull_request.edited", key: "changes.title.from"},
+		{eventName: "pull_request", fixture: "pull_request.edited", key: "pull_request.base.ref"},
+		{eventName: "pull_request", fixture: "pull_request.labeled", key: "label.name"},
+		{eventName: "pull_request", fixture: "pull_request.unlabeled", key: "pull_request.title"},
+		{eventName: "pull_request", fixture: "pull_request.milestoned", key: "pull_request.milestone.title"},
+		{eventName: "pull_request", fixture: "pull_request.locked", key: "pull_request.active_lock_reason"},
+		{eventName: "pull_request", fixture: "pull_request.closed", key: "pull_request.title"},
+		{eventName: "pull_request", fixture: "pull_request", key: "pull_request.title"},
+		{eventName: "issue_comment", fixture: "issue_comment", key: "issue.title"},
+		{eventName: "issue_comment", fixture: "issue_comment", key: "comment.body", text: markdown},
+		{eventName: "pull_request_review", fixture: "pull_request_review", key: "pull_request.title"},
+		{eventName: "pull_request_review", fixture: "pull_request_review", key: "review.body", text: markdown},
+		{eventName: "pull_request_review_comment", fixture: "pull_request_review_comment", key: "pull_request.title"},
+		{eventName: "pull_request_review_comment", fixture: "pull_request_review_comment", key: "comment.body", text: markdown},
+		{eventName: "push", fixture: "push", key: "commits.0.message", text: markdown},
+		{eventName: "deployment_status", fixture: "deployment_status", key: "deployment_status.description"},
+	}
+
+	for _, tt := range tests {
+		c.Run(tt.fixture+"/"+tt.key, func(c *qt.C) {
+			var payload []byte
+			poster := &MockPoster{
+				PostFn: func(ctx context.Context, reader io.Reader) (err error) {
+					payload, err = io.ReadAll(reader)
+					return err
+				},
+			}
+			ec := createContext(c, "biscuits", "jeff", tt.eventName, tt.fixture)
+			want := text
+			if tt.text != "" {
+				want = tt.text
+			}
+			set(c, ec.event, tt.key, want)
+
+			c.Assert(handler.New(poster).Handle(ec), qt.IsNil)
+			var msg any
+			c.Assert(json.Unmarshal(payload, &msg), qt.IsNil, qt.Commentf("%s", payload))
+			c.Assert(containsText(msg, want), qt.IsTrue, qt.Commentf("%s", payload))
+		})
+	}
+}
+
+func TestHandler_HandleEscapes(t *testing.T) {
+	c := qt.New(t)
+
+	const text = "a <b> & c"
+	const escaped = "a &lt;b&gt; &amp; c"
+	tests := []struct {
+		eventName string
+		fixture   string
+		key       string
+		value     string
+	}{
+		{eventName: "create", fixture: "create", key: "ref"},
+		{eventName: "delete", fixture: "delete", key: "ref"},
+		{eventName: "deployment", fixture: "deployment", key: "deployment.environment"},
+		{eventName: "deployment", fixture: "deployment", key: "deployment.ref"},
+		{eventName: "deployment", fixture: "deployment", key: "deployment.task"},
+		{eventName: "deployment_status", fixture: "deployment_status", key: "deployment_status.description"},
+		{eventName: "discussion", fixture: "discussion.created", key: "discussion.title"},
+		{eventName: "discussion", fixture: "discussion.created", key: "discussion.category.name"},
+		{eventName: "discussion", fixture: "discussion.answered", key: "discussion.category.name"},
+		{eventName: "discussion", fixture: "discussion.category_changed", key: "changes.category.from.name"},
+		{eventName: "issue_comment", fixture: "issue_comment", key: "issue.title"},
+		{eventName: "pull_request", fixture: "pull_request", key: "pull_request.title"},
+		{eventName: "pull_request", fixture: "pull_request.auto_merge_disabled", key: "reason"},
+		{eventName: "pull_request", fixture: "pull_request.closed", key: "pull_request.base.ref"},
+		{eventName: "pull_request", fixture: "pull_request.edited", key: "changes.title.from"},
+		{eventName: "pull_request", fixture: "pull_request.edited", key: "pull_request.base.ref"},
+		{eventName: "pull_request", fixture: "pull_request.labeled", key: "label.name"},
+		{eventName: "pull_request", fixture: "pull_request.labeled", key: "pull_request.labels.0.name"},
+		{eventName: "pull_request", fixture: "pull_request.milestoned", key: "pull_request.milestone.title"},
+		{eventName: "pull_request", fixture: "pull_request.review_requested", key: "pull_request.title"},
+		{eventName: "push", fixture: "push", key: "ref", value: "refs/heads/" + text},
+		{eventName: "push", fixture: "push.deleted", key: "ref", value: "refs/heads/" + text},
+	}
+
+	for _, tt := range tests {
+		c.Run(tt.fixture+"/"+tt.key, func(c *qt.C) {
+			var payload []byte
+			poster := &MockPoster{
+				PostFn: func(ctx context.Context, reader io.Reader) (err error) {
+					payload, err = io.ReadAll(reader)
+					return err
+				},
+			}
+			ec := createContext(c, "biscuits", "jeff", tt.eventName, tt.fixture)
+			value := text
+			if tt.value != "" {
+				value = tt.value
+			}
+			set(c, ec.event, tt.key, value)
+
+			c.Assert(handler.New(poster).Handle(ec), qt.IsNil)
+			var msg any
+			c.Assert(json.Unmarshal(payload, &msg), qt.IsNil, qt.Commentf("%s", payload))
+			c.Assert(containsText(msg, escaped), qt.IsTrue, qt.Commentf("%s", payload))
+			c.Assert(containsText(msg, text), qt.IsFalse, qt.Commentf("%s", payload))
+		})
+	}
+}
+
+// set replaces the value at a dotted key of an event.
+func set(c *qt.C, event any, key string, value any) {
+	keys := strings.Split(key, ".")
+	v := event
+	for _, k := range keys[:len(keys)-1] {
+		switch e := v.(type) {
+		case map[string]any:
+			v = e[k]
+		case []any:
+			i, err := strconv.Atoi(k)
+			c.Assert(err, qt.IsNil)
+			v = e[i]
+		}
+	}
+	m, _ := v.(map[string]any)
+	c.Assert(m, qt.Not(qt.IsNil), qt.Commentf("no %s in the event", key))
+	m[keys[len(keys)-1]] = value
+}
+
+// containsText reports whether any string in a message contains the text.
+func containsText(v any, text string) bool {
+	switch v := v.(type) {
+	case string:
+		return strings.Contains(v, text)
+	case map[string]any:
+		for _, e := range v {
+			if containsText(e, text) {
+				return true
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if containsText(e, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestHandler_HandleReviewAggregation(t *testing.T) {
+	c := qt.New(t)
+
+	posted := 0
+	poster := &MockPoster{
+		PostFn: func(ctx context.Context, reader io.Reader) error {
+			posted++
+			return nil
+		},
+	}
+	ec := createContext(c, "biscuits", "jeff", "pull_request_review", "pull_request_review")
+
+	err := handler.New(poster, handler.WithReviewAggregation()).Handle(ec)
+	c.Assert(err, qt.ErrorMatches, "aggregating reviews needs the GitHub API, set github_token")
+
+	// a review without a body or comments says nothing
+	review := ec.event.(map[string]any)["review"].(map[string]any)
+	review["state"] = "commented"
+	review["body"] = nil
+	h := handler.New(poster,
+		handler.WithGitHub(&MockGitHub{}),
+		handler.WithReviewAggregation(),
+	)
+	c.Assert(h.Handle(ec), qt.IsNil)
+	c.Assert(posted, qt.Equals, 0)
+
+	review["state"] = "approved"
+	c.Assert(h.Handle(ec), qt.IsNil)
+	c.Assert(posted, qt.Equals, 1)
+}
+
+func TestHandler_React(t *testing.T) {
+	c := qt.New(t)
+
+	var reactions []string
+	reactor := &MockReactor{
+		AddFn: func(ctx context.Context, ref store.Ref, name string) error {
+			c.Check(ref, qt.Equals, store.Ref{Channel: "C123", TS: "1662454800.000100"})
+			reactions = append(reactions, "+"+name)
+			return nil
+		},
+		RemoveFn: func(ctx context.Context, ref store.Ref, name string) error {
+			reactions = append(reactions, "-"+name)
+			return nil
+		},
 	}
-	err := h.Handle(ec)
+	poster := &MockUpdater{}
+	h := handler.New(poster,
+		handler.WithStore(store.NewMemory()),
+		handler.WithReactions(reactor, handler.DefaultReactions),
+	)
 
+	// nothing to react to until the pull request is announced
+	review := createContext(c, "biscuits", "jeff", "pull_request_review", "pull_request_review")
+	ok, err := h.React(review)
 	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+
+	reopened := createContext(c, "biscuits", "jeff", "pull_request", "pull_request.reopened")
+	ok, err = h.React(reopened)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+	c.Assert(h.Handle(reopened), qt.IsNil)
+	c.Assert(poster.posted, qt.HasLen, 1)
+
+	ok, err = h.React(review)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+
+	closed := createContext(c, "biscuits", "jeff", "pull_request", "pull_request.closed")
+	ok, err = h.React(closed)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+
+	c.Assert(reactions, qt.DeepEquals, []string{
+		"-white_check_mark",
+		"+repeat",
+		"-no_entry_sign",
+		"+large_purple_circle",
+	})
+}
+
+type MockReactor struct {
+	AddFn    func(ctx context.Context, ref store.Ref, name string) error
+	RemoveFn func(ctx context.Context, ref store.Ref, name string) error
+}
+
+func (m *MockReactor) AddReaction(ctx context.Context, ref store.Ref, name string) error {
+	return m.AddFn(ctx, ref, name)
+}
+
+func (m *MockReactor) RemoveReaction(ctx context.Context, ref store.Ref, name string) error {
+	return m.RemoveFn(ctx, ref, name)
+}
+
+// MockUpdater records the messages posted and updated.
+type MockUpdater struct {
+	posted, updated []string
+}
+
+func (m *MockUpdater) Post(ctx context.Context, reader io.Reader) error {
+	_, err := m.PostRef(ctx, reader)
+	return err
+}
+
+func (m *MockUpdater) PostRef(ctx context.Context, reader io.Reader) (store.Ref, error) {
+	b, err := io.ReadAll(reader)
+	m.posted = append(m.posted, string(b))
+	return store.Ref{Channel: "C123", TS: "1662454800.000100"}, err
+}
+
+func (m *MockUpdater) Update(ctx context.Context, ref store.Ref, reader io.Reader) error {
+	b, err := io.ReadAll(reader)
+	m.updated = append(m.updated, string(b))
+	return err
+}
+
+type historyFunc func(ctx context.Context, channel string, since time.Time) ([]store.Message, error)
+
+func (f historyFunc) History(ctx context.Context, channel string, since time.Time) ([]store.Message, error) {
+	return f(ctx, channel, since)
+}
+
+func TestHandler_HandleReminders(t *testing.T) {
+	c := qt.New(t)
+
+	posted := 0
+	poster := &MockPoster{
+		PostFn: func(ctx context.Context, reader io.Reader) error {
+			posted++
+			return nil
+		},
+	}
+	ec := createContext(c, "biscuits", "jeff", "schedule", "schedule")
+
+	err := handler.New(poster).Handle(ec)
+	c.Assert(err, qt.ErrorMatches, "reminders need the GitHub API, set github_token")
+
+	// nothing waiting for more than a fortnight
+	h := handler.New(poster,
+		handler.WithGitHub(recordedGitHub(c)),
+		handler.WithStaleReminders(14*24*time.Hour, nil),
+		handler.WithClock(func() time.Time { return now }),
+	)
+	c.Assert(h.Handle(ec), qt.IsNil)
+	c.Assert(posted, qt.Equals, 0)
+}
+
+func TestHandler_HandleCloseReason(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		name    string
+		comment *github.Comment
+		want    string
+	}{
+		{
+			name:    "Comment by closer",
+			comment: &github.Comment{Body: "Superseded by **#15**", User: github.User{Login: "jeff"}},
+			want:    "Superseded by *#15*",
+		},
+		{
+			name:    "Comment by someone else",
+			comment: &github.Comment{Body: "LGTM", User: github.User{Login: "togglebuild"}},
+		},
+		{
+			name: "No comments",
+		},
+	}
+
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			var payload []byte
+			poster := &MockPoster{
+				PostFn: func(ctx context.Context, reader io.Reader) (err error) {
+					payload, err = io.ReadAll(reader)
+					return err
+				},
+			}
+			gh := &MockGitHub{
+				LastCommentFn: func(ctx context.Context, repo string, number, count int) (*github.Comment, error) {
+					c.Check(repo, qt.Equals, "spaceweasel/jeff-test")
+					c.Check(number, qt.Equals, 14)
+					c.Check(count, qt.Equals, 3)
+					return tt.comment, nil
+				},
+			}
+
+			h := handler.New(poster, handler.WithGitHub(gh))
+			err := h.Handle(createContext(c, "biscuits", "jeff", "pull_request", "pull_request.closed_unmerged"))
+			c.Assert(err, qt.IsNil)
+			c.Assert(json.Valid(payload), qt.IsTrue, qt.Commentf("%s", payload))
+			if tt.want == "" {
+				c.Assert(string(payload), qt.Not(qt.Contains), "Reason")
+				return
+			}
+			c.Assert(string(payload), qt.Contains, tt.want)
+		})
+	}
+}
+
+func TestHandler_User(t *testing.T) {
+	c := qt.New(t)
+
+	users := handler.WithUsers(map[string]string{"togglebuild": "togglebuild.smith"})
+	h := handler.New(&MockPoster{}, users, handler.WithBackend(handler.Backends["mattermost"]))
+	c.Assert(h.User("togglebuild"), qt.Equals, "@togglebuild.smith")
+	c.Assert(h.User("jeff"), qt.Equals, "<https://github.com/jeff|jeff>")
+
+	h = handler.New(&MockPoster{}, users, handler.WithBackend(handler.Backends["discord"]))
+	c.Assert(h.User("jeff"), qt.Equals, "[jeff](https://github.com/jeff)")
+
+	// backends only support some events, skipping the rest
+	posted := false
+	poster := &MockPoster{
+		PostFn: func(ctx context.Context, reader io.Reader) error {
+			posted = true
+			return nil
+		},
+	}
+	h = handler.New(poster, handler.WithBackend(handler.Backends["discord"]))
+	ec := createContext(c, "biscuits", "jeff", "star", "star")
+	c.Assert(h.Handle(ec), qt.IsNil)
+	c.Assert(posted, qt.IsFalse)
 }
 
-func createContext(c *qt.C, channel, actor, eventname string) *testContext {
-	f, err := os.Open(fmt.Sprintf("testdata/%s.json", eventname))
+func createContext(c *qt.C, channel, actor, eventname, fixture string) *testContext {
+	f, err := os.Open(fmt.Sprintf("testdata/%s.json", fixture))
 	c.Assert(err, qt.IsNil)
 	defer f.Close()
 
@@ -53,13 +1107,16 @@ func createContext(c *qt.C, channel, actor, eventname string) *testContext {
 }
 
 type testContext struct {
+	id        string
 	channel   string
 	actor     string
 	eventName string // e.g. pull_request
 	event     any    // ["action"] == "opened"
-	branch    string
 }
 
+func (e *testContext) ID() string {
+	return e.id
+}
 func (e *testContext) Channel() string {
 	return e.channel
 }
@@ -79,13 +1136,26 @@ func (e *testContext) Action() string {
 }
 
 func (e *testContext) Branch() string {
-	return e.branch
+	ref, _ := e.Get("ref").(string)
+	return strings.TrimPrefix(ref, "refs/heads/")
 }
 
 func (e *testContext) Event() any {
 	return e.event
 }
 
+func (e *testContext) Get(key string) any {
+	v := e.event
+	for _, k := range strings.Split(key, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
 type MockPoster struct {
 	PostFn func(ctx context.Context, reader io.Reader) error
 }
@@ -96,3 +1166,142 @@ func (m *MockPoster) Post(ctx context.Context, reader io.Reader) error {
 	}
 	return m.PostFn(ctx, reader)
 }
+
+// slackChannel posts to, updates and lists the history of a channel, keeping
+// the metadata of its messages as Slack does.
+type slackChannel struct {
+	msgs    []store.Message
+	updates int
+	// text is that of the last message posted or updated
+	text string
+}
+
+func (s *slackChannel) Post(ctx context.Context, reader io.Reader) error {
+	_, err := s.PostRef(ctx, reader)
+	return err
+}
+
+func (s *slackChannel) PostRef(ctx context.Context, reader io.Reader) (store.Ref, error) {
+	md, err := s.read(reader)
+	if err != nil {
+		return store.Ref{}, err
+	}
+	ts := fmt.Sprintf("1662454800.%06d", len(s.msgs)+1)
+	// newest first, as in conversations.history
+	s.msgs = append([]store.Message{{TS: ts, Metadata: md}}, s.msgs...)
+	return store.Ref{Channel: "C0123", TS: ts}, nil
+}
+
+func (s *slackChannel) Update(ctx context.Context, ref store.Ref, reader io.Reader) error {
+	md, err := s.read(reader)
+	if err != nil {
+		return err
+	}
+	for i := range s.msgs {
+		if s.msgs[i].TS == ref.TS {
+			s.msgs[i].Metadata = md
+		}
+	}
+	s.updates++
+	return nil
+}
+
+func (s *slackChannel) History(ctx context.Context, channel string, since time.Time) ([]store.Message, error) {
+	return s.msgs, nil
+}
+
+func (s *slackChannel) read(reader io.Reader) (store.Metadata, error) {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return store.Metadata{}, err
+	}
+	var msg struct {
+		Metadata store.Metadata
+	}
+	s.text = string(b)
+	return msg.Metadata, json.Unmarshal(b, &msg)
+}
+
+type openerFunc func(ctx context.Context, user string) (string, error)
+
+func (f openerFunc) OpenConversation(ctx context.Context, user string) (string, error) {
+	return f(ctx, user)
+}
+
+type MockGitHub struct {
+	LastCommentFn      func(ctx context.Context, repo string, number, count int) (*github.Comment, error)
+	OpenPullRequestsFn func(ctx context.Context, repo string) ([]github.PullRequest, error)
+}
+
+func (m *MockGitHub) OpenPullRequests(ctx context.Context, repo string) ([]github.PullRequest, error) {
+	if m.OpenPullRequestsFn == nil {
+		return nil, nil
+	}
+	return m.OpenPullRequestsFn(ctx, repo)
+}
+
+func (m *MockGitHub) ReviewComments(ctx context.Context, repo string, number int, reviewID int64) ([]github.ReviewComment, error) {
+	return nil, nil
+}
+
+func (m *MockGitHub) PullRequests(ctx context.Context, repo string, since time.Time) ([]github.PullRequest, error) {
+	return nil, nil
+}
+
+func (m *MockGitHub) Reviews(ctx context.Context, repo string, number int) ([]github.Review, error) {
+	return nil, nil
+}
+
+func (m *MockGitHub) IssueEvents(ctx context.Context, repo string, number int) ([]github.IssueEvent, error) {
+	return nil, nil
+}
+
+func (m *MockGitHub) Releases(ctx context.Context, repo string, since time.Time) ([]github.Release, error) {
+	return nil, nil
+}
+
+func (m *MockGitHub) FailedRuns(ctx context.Context, repo string, since time.Time) ([]github.WorkflowRun, error) {
+	return nil, nil
+}
+
+// recordedGitHub serves API responses recorded in testdata/github, named by
+// the path requested, e.g. reviews_14.json.
+func recordedGitHub(c *qt.C) *github.Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/repos/spaceweasel/jeff-test/"), "/")
+		var name string
+		switch {
+		case parts[0] == "pulls" && len(parts) == 1:
+			name = "pulls_" + r.URL.Query().Get("state")
+		case parts[0] == "pulls" && len(parts) == 5:
+			name = "review_comments_" + parts[3]
+		case parts[0] == "pulls" && parts[2] == "reviews":
+			name = "reviews_" + parts[1]
+		case parts[0] == "issues" && parts[2] == "events":
+			name = "events_" + parts[1]
+		case parts[0] == "releases":
+			name = "releases"
+		case parts[0] == "actions":
+			name = "runs"
+		default:
+			w.Write([]byte("[]"))
+			return
+		}
+		b, err := os.ReadFile(filepath.Join("testdata", "github", name+".json"))
+		c.Check(err, qt.IsNil)
+		w.Write(b)
+	}))
+	c.Cleanup(srv.Close)
+
+	return github.NewClient("", github.WithBaseURL(srv.URL))
+}
+
+// now is the time scheduled events are handled in tests.
+var now = time.Date(2022, 9, 6, 9, 0, 0, 0, time.UTC)
+
+func (m *MockGitHub) LastComment(ctx context.Context, repo string, number, count int) (*github.Comment, error) {
+	if m.LastCommentFn == nil {
+		return nil, nil
+	}
+	return m.LastCommentFn(ctx, repo, number, count)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"strings"
+)
+
+const defaultCommitLimit = 10
+
+type pushSummary struct {
+	// Count is the number of distinct commits pushed.
+	Count int
+	// Total is the number of commits pushed in a burst of pushes coalesced
+	// into one message, otherwise Count.
+	Total int
+	// Groups holds the commits shown, grouped by author in the order pushed.
+	Groups []commitGroup
+	// More is the number of commits not shown.
+	More int
+}
+
+type commitGroup struct {
+	Author  string
+	Commits []commit
+}
+
+type commit struct {
+	ID      string
+	URL     string
+	Subject string
+}
+
+// summarisePush collects the distinct commits of a push event, showing at most
+// limit of them.
+func summarisePush(ec EventContext, limit int) pushSummary {
+	var s pushSummary
+	groups := make(map[string]int)
+
+	commits, _ := ec.Get("commits").([]any)
+	for _, v := range commits {
+		m, _ := v.(map[string]any)
+		if distinct, ok := m["distinct"].(bool); ok && !distinct {
+			// already announced when pushed to another branch
+			continue
+		}
+		s.Count++
+		if s.Count > limit {
+			s.More++
+			continue
+		}
+
+		id, _ := m["id"].(string)
+		url, _ := m["url"].(string)
+		msg, _ := m["message"].(string)
+		subject, _, _ := strings.Cut(msg, "\n")
+		c := commit{
+			ID:      id,
+			URL:     url,
+			Subject: strings.TrimSpace(subject),
+		}
+
+		author := commitAuthor(m)
+		if i, ok := groups[author]; ok {
+			s.Groups[i].Commits = append(s.Groups[i].Commits, c)
+			continue
+		}
+		groups[author] = len(s.Groups)
+		s.Groups = append(s.Groups, commitGroup{Author: author, Commits: []commit{c}})
+	}
+	s.Total = s.Count
+
+	return s
+}
+
+func commitAuthor(m map[string]any) string {
+	author, _ := m["author"].(map[string]any)
+	if name, _ := author["username"].(string); name != "" {
+		return name
+	}
+	name, _ := author["name"].(string)
+	return name
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spaceweasel/slackhub/pkg/store"
+)
+
+// Reactor is implemented by posters able to react to posted messages.
+type Reactor interface {
+	AddReaction(ctx context.Context, ref store.Ref, name string) error
+	RemoveReaction(ctx context.Context, ref store.Ref, name string) error
+}
+
+// DefaultReactions maps the status of a pull request to the emoji its root
+// message is reacted to with.
+var DefaultReactions = map[string]string{
+	"approved":          "white_check_mark",
+	"changes_requested": "repeat",
+	"merged":            "large_purple_circle",
+	"closed":            "no_entry_sign",
+}
+
+// reactionGroups hold the statuses which replace each other, so the reaction
+// for a review is removed once another is submitted.
+var reactionGroups = [][]string{
+	{"approved", "changes_requested"},
+	{"open", "merged", "closed"},
+}
+
+// reactionStatus returns the status of the pull request an event changes, if
+// any.
+func reactionStatus(ec EventContext) string {
+	switch ec.Name() + "." + ec.Action() {
+	case "pull_request_review.submitted":
+		state, _ := ec.Get("review.state").(string)
+		return state
+	case "pull_request.closed":
+		if ec.Get("pull_request.merged") == true {
+			return "merged"
+		}
+		return "closed"
+	case "pull_request.reopened":
+		return "open"
+	}
+	return ""
+}
+
+// React reacts to the root message of the pull request an event is about,
+// removing reactions it replaces, and reports whether that stands in for
+// posting a message. Without a root message, a message is posted as usual.
+func (h *Handler) React(ec EventContext) (bool, error) {
+	if h.reactor == nil || h.store == nil {
+		return false, nil
+	}
+	status := reactionStatus(ec)
+	var group []string
+	for _, g := range reactionGroups {
+		for _, s := range g {
+			if s == status {
+				group = g
+			}
+		}
+	}
+	if group == nil {
+		return false, nil
+	}
+
+	ctx := context.Background()
+	key := rootKey(ec)
+	ref, ok, err := h.storeFor(ec.Channel()).Get(ctx, key)
+	if err != nil {
+		h.log.Warningf("could not find the pull request message, %v", err)
+		return false, nil
+	}
+	if !ok || ref.TS == "" {
+		return false, nil
+	}
+
+	for _, s := range group {
+		emoji, ok := h.reactions[s]
+		if s == status || !ok {
+			continue
+		}
+		if err := h.reactor.RemoveReaction(ctx, ref, emoji); err != nil {
+			return false, fmt.Errorf("could not remove reaction, %w", err)
+		}
+	}
+
+	emoji, ok := h.reactions[status]
+	if !ok {
+		return false, nil
+	}
+	if err := h.reactor.AddReaction(ctx, ref, emoji); err != nil {
+		return false, fmt.Errorf("could not add reaction, %w", err)
+	}
+	h.log.Infof("Reacted with :%s: to %s", emoji, key)
+
+	return true, nil
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spaceweasel/slackhub/pkg/github"
+)
+
+const (
+	// maxHunkLines is how many lines of a diff hunk are shown, up to and
+	// including the line commented on.
+	maxHunkLines = 6
+	// maxHunkWidth truncates long lines, such as minified files.
+	maxHunkWidth = 120
+)
+
+// snippet is the code a review comment refers to.
+type snippet struct {
+	Path string
+	// Lines is the line, or range of lines, commented on, e.g. 5-7.
+	Lines string
+	// URL links to the lines in the diff of the pull request.
+	URL string
+	// Hunk is the end of the diff hunk, keeping + and - markers.
+	Hunk string
+}
+
+// reviewSnippet returns the code a review comment refers to, and whether the
+// comment is on lines of the diff rather than a whole file.
+func reviewSnippet(c github.ReviewComment, pullURL string) (snippet, bool) {
+	if c.Path == "" || c.Line == 0 {
+		return snippet{}, false
+	}
+
+	side := diffSide(c.Side, "R")
+	s := snippet{
+		Path:  c.Path,
+		Lines: fmt.Sprint(c.Line),
+		URL:   fmt.Sprintf("%s/files#diff-%s%s%d", pullURL, hashPath(c.Path), side, c.Line),
+		Hunk:  trimHunk(c.DiffHunk),
+	}
+	if c.StartLine != 0 && c.StartLine != c.Line {
+		startSide := diffSide(c.StartSide, side)
+		s.Lines = fmt.Sprintf("%d-%d", c.StartLine, c.Line)
+		s.URL = fmt.Sprintf("%s/files#diff-%s%s%d-%s%d", pullURL, hashPath(c.Path), startSide, c.StartLine, side, c.Line)
+	}
+
+	return s, true
+}
+
+// diffSide returns the prefix of line anchors for a side of the diff, L for
+// the base and R for the head.
+func diffSide(side, def string) string {
+	switch side {
+	case "LEFT":
+		return "L"
+	case "RIGHT":
+		return "R"
+	}
+	return def
+}
+
+// originalCode returns the lines a review comment is on, which any change it
+// suggests replaces, without their diff markers.
+func originalCode(c github.ReviewComment) string {
+	if c.Line == 0 {
+		return ""
+	}
+	n := 1
+	if c.StartLine != 0 && c.StartLine < c.Line {
+		n = c.Line - c.StartLine + 1
+	}
+
+	// lines of the other side of the diff aren't numbered on this one
+	other := "-"
+	if c.Side == "LEFT" {
+		other = "+"
+	}
+	var lines []string
+	for i, l := range strings.Split(strings.TrimRight(c.DiffHunk, "\n"), "\n") {
+		if (i == 0 && strings.HasPrefix(l, "@@")) || l == "" || strings.HasPrefix(l, other) {
+			continue
+		}
+		lines = append(lines, l[1:])
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hashPath identifies a file in the diff of a pull request, as in the anchors
+// GitHub links to.
+func hashPath(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return hex.EncodeToString(sum[:])
+}
+
+// trimHunk returns the last lines of a diff hunk, which ends at the line
+// commented on, dropping the @@ header and truncating long lines.
+func trimHunk(hunk string) string {
+	lines := strings.Split(strings.TrimRight(hunk, "\n"), "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "@@") {
+		lines = lines[1:]
+	}
+	if len(lines) > maxHunkLines {
+		lines = lines[len(lines)-maxHunkLines:]
+	}
+	for i, l := range lines {
+		if utf8.RuneCountInString(l) > maxHunkWidth {
+			lines[i] = string([]rune(l)[:maxHunkWidth-1]) + "…"
+		}
+		// a fence within the code would end the block early
+		lines[i] = strings.ReplaceAll(lines[i], "```", "`​``")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maxReviewComments limits how many comments of a review are listed.
+const maxReviewComments = 20
+
+type reviewSummary struct {
+	Comments []reviewComment
+	// More is the number of comments not listed.
+	More int
+}
+
+type reviewComment struct {
+	// Label is the path commented on, with the lines if not the whole file,
+	// e.g. main.go:5-7.
+	Label string
+	URL   string
+	Body  string
+	// Original is the code commented on, replaced by any suggested change.
+	Original string
+}
+
+// summariseReview collects the comments of a submitted review, which are
+// otherwise each delivered as an event of their own.
+func summariseReview(ctx context.Context, gh GitHub, ec EventContext) (reviewSummary, error) {
+	var s reviewSummary
+	repo, _ := ec.Get("repository.full_name").(string)
+	number, _ := ec.Get("pull_request.number").(float64)
+	id, _ := ec.Get("review.id").(float64)
+	pullURL, _ := ec.Get("pull_request.html_url").(string)
+
+	comments, err := gh.ReviewComments(ctx, repo, int(number), int64(id))
+	if err != nil {
+		return s, err
+	}
+	for i, c := range comments {
+		if i >= maxReviewComments {
+			s.More = len(comments) - maxReviewComments
+			break
+		}
+		rc := reviewComment{Label: c.Path, URL: c.HTMLURL, Body: c.Body, Original: originalCode(c)}
+		if sn, ok := reviewSnippet(c, pullURL); ok {
+			rc.Label += ":" + sn.Lines
+		}
+		s.Comments = append(s.Comments, rc)
+	}
+
+	return s, nil
+}
+
+// eventComment decodes the review comment of an event.
+func eventComment(ec EventContext) (github.ReviewComment, error) {
+	var c github.ReviewComment
+	b, err := json.Marshal(ec.Get("comment"))
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/spaceweasel/slackhub/pkg/slack"
+)
+
+// builder builds the Slack message for an action, whose template only defines
+// the text that varies between actions, such as the pretext. The text is
+// escaped for JSON when the message is marshalled, while the template escapes
+// event text for mrkdwn with SlackEscape.
+type builder func(h *Handler, msg message, text func(name string) (string, error)) (*slack.Message, error)
+
+// slackBuilders are the builders of Slack messages by qualified action, used
+// instead of rendering the whole message with a template.
+var slackBuilders = map[string]builder{
+	"create.default": attachment{color: "#24292f"}.build,
+	"delete.default": attachment{color: "#959da5"}.build,
+	"deployment.created": attachment{
+		color:  "#0969da",
+		ts:     "deployment.created_at",
+		fields: deploymentFields(field{title: "Task", name: "task", short: true}),
+	}.build,
+	"deployment_status.created": attachment{
+		ts: "deployment_status.updated_at",
+		fields: deploymentFields(
+			field{title: "State", name: "state", short: true},
+			field{title: "Creator", name: "creator", short: true}),
+	}.build,
+	"discussion.answered": attachment{
+		color:  "#36a64f",
+		object: "discussion",
+		ts:     "discussion.answer_chosen_at",
+		fields: []field{
+			{title: "Answer", name: "body"},
+			{title: "Category", name: "category", short: true},
+		},
+	}.build,
+	"discussion.category_changed":      attachment{color: "#0969da", object: "discussion", ts: "discussion.updated_at"}.build,
+	"discussion.created":               attachment{color: "#0969da", object: "discussion", ts: "discussion.created_at", fields: []field{body}}.build,
+	"discussion_comment.created":       attachment{color: "#0969da", object: "discussion", ts: "comment.updated_at", fields: []field{body}}.build,
+	"fork.default":                     attachment{color: "#24292f", ts: "forkee.created_at"}.build,
+	"issue_comment.created":            attachment{color: "#36a64f", object: "issue", ts: "comment.updated_at", fields: []field{body}}.build,
+	"pull_request.assigned":            pullRequestAttachment("#0969da").build,
+	"pull_request.auto_merge_disabled": pullRequestAttachment("#959da5").build,
+	"pull_request.auto_merge_enabled": pullRequestAttachment("#36a64f",
+		field{title: "Merge method", name: "merge_method", short: true}).build,
+	"pull_request.closed": pullRequestAttachment("",
+		field{title: "Merge commit", name: "merge_commit", short: true, optional: true},
+		field{title: "Base", name: "base", short: true, optional: true},
+		field{title: "Merged by", name: "merged_by", short: true, optional: true},
+		field{title: "Changes", name: "changes", short: true, optional: true},
+		field{title: "Reason", name: "reason", optional: true}).build,
+	"pull_request.converted_to_draft": pullRequestAttachment("#959da5").build,
+	"pull_request.edited": pullRequestAttachment("#36a64f",
+		field{title: "Title", name: "title_change"},
+		field{title: "Description", name: "description", short: true},
+		field{title: "Base", name: "base", short: true}).build,
+	"pull_request.labeled":                pullRequestAttachment("", labels).build,
+	"pull_request.locked":                 pullRequestAttachment("#959da5").build,
+	"pull_request.milestoned":             pullRequestAttachment("#36a64f", field{title: "Due", name: "due", short: true}).build,
+	"pull_request.opened":                 pullRequestMessage(true),
+	"pull_request.ready_for_review":       pullRequestMessage(true),
+	"pull_request.reopened":               pullRequestMessage(true),
+	"pull_request.review_request_removed": pullRequestAttachment("#959da5").build,
+	"pull_request.review_requested":       pullRequestAttachment("#0969da").build,
+	"pull_request.synchronize":            pullRequestMessage(false),
+	"pull_request.unassigned":             pullRequestAttachment("#959da5").build,
+	"pull_request.unlabeled":              pullRequestAttachment("#959da5", labels).build,
+	"pull_request_review.submitted":       attachment{object: "pull_request", ts: "review.submitted_at", fields: []field{body}}.build,
+	"pull_request_review_comment.created": attachment{color: "#36a64f", object: "pull_request", ts: "comment.updated_at", fields: []field{body}}.build,
+	"push.default": attachment{
+		ts: "head_commit.timestamp",
+		fields: []field{
+			{title: "Force-pushed", name: "forced", optional: true},
+			{name: "commits", optional: true},
+		},
+	}.build,
+	"schedule.default": attachment{color: "#dbab09"}.build,
+	"schedule.digest":  attachment{color: "#0366d6"}.build,
+	"star.created":     attachment{color: "#e3b341", ts: "starred_at"}.build,
+	"star.deleted":     attachment{color: "#959da5"}.build,
+	"watch.started":    attachment{color: "#24292f"}.build,
+}
+
+var (
+	// body is the field holding the body of a comment, review or discussion.
+	body   = field{name: "body"}
+	labels = field{title: "Labels", name: "labels", short: true}
+)
+
+// attachment describes a message of one attachment, whose text comes from the
+// templates named pretext, text and color, and those named by its fields. The
+// attachment is titled with, and linked to, the object at an event key, such as
+// pull_request, unless the templates named title and title_link define it.
+type attachment struct {
+	// color is used unless the color template defines one.
+	color  string
+	object string
+	// ts is the event key of the time shown in the footer, the current time if
+	// empty.
+	ts     string
+	fields []field
+}
+
+// field is an attachment field, with its value from the template named name.
+type field struct {
+	title string
+	name  string
+	short bool
+	// optional fields are left out if their value is empty.
+	optional bool
+}
+
+func pullRequestAttachment(color string, fields ...field) attachment {
+	return attachment{color: color, object: "pull_request", ts: "pull_request.updated_at", fields: fields}
+}
+
+// deploymentFields describe what is deployed, and where, followed by extra.
+func deploymentFields(extra ...field) []field {
+	return append([]field{
+		{title: "Environment", name: "environment", short: true},
+		{title: "Ref", name: "ref", short: true},
+		{title: "SHA", name: "sha", short: true},
+	}, extra...)
+}
+
+func (a attachment) build(h *Handler, msg message, text func(string) (string, error)) (*slack.Message, error) {
+	t := make(map[string]string)
+	for _, name := range []string{"color", "pretext", "title", "title_link", "text"} {
+		s, err := text(name)
+		if err != nil {
+			return nil, err
+		}
+		t[name] = s
+	}
+	if t["color"] == "" {
+		t["color"] = a.color
+	}
+	if obj, ok := msg.Get(a.object).(map[string]any); ok && t["title"] == "" {
+		title, _ := obj["title"].(string)
+		t["title"] = slack.Escape(title)
+		t["title_link"], _ = obj["html_url"].(string)
+	}
+	ts, _ := msg.Get(a.ts).(string)
+
+	att := slack.NewAttachment(t["color"]).
+		WithTitle(t["title"], t["title_link"]).
+		WithFooter(repositoryFooter(msg), AsTimestamp(ts))
+	att.Pretext = t["pretext"]
+	att.Text = t["text"]
+	for _, f := range a.fields {
+		value, err := text(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if f.optional && value == "" {
+			continue
+		}
+		att.AddField(f.title, value, f.short)
+	}
+
+	return slack.NewMessage(msg.Channel()).Attach(att), nil
+}
+
+// build builds a message, with text from the templates defined by tpl. Text
+// from templates tpl doesn't define is empty.
+func (h *Handler) build(b builder, tpl *template.Template, msg message) ([]byte, error) {
+	text := func(name string) (string, error) {
+		if tpl.Lookup(name) == nil {
+			return "", nil
+		}
+		out := bytes.NewBuffer(nil)
+		if err := tpl.ExecuteTemplate(out, name, msg); err != nil {
+			return "", fmt.Errorf("could not execute template, %w", err)
+		}
+		return out.String(), nil
+	}
+
+	m, err := b(h, msg, text)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// pullRequestMessage builds a message about a pull request, with its
+// requested reviewers and labels, and its description if withBody is set.
+func pullRequestMessage(withBody bool) builder {
+	return func(h *Handler, msg message, text func(string) (string, error)) (*slack.Message, error) {
+		pretext, err := text("pretext")
+		if err != nil {
+			return nil, err
+		}
+		pr, _ := msg.Get("pull_request").(map[string]any)
+		title, _ := pr["title"].(string)
+		url, _ := pr["html_url"].(string)
+		updated, _ := pr["updated_at"].(string)
+
+		a := slack.NewAttachment("#36a64f").
+			WithTitle(slack.Escape(title), url).
+			WithFooter(repositoryFooter(msg), AsTimestamp(updated))
+		a.Pretext = pretext
+		if withBody {
+			a.AddField("", h.SlackMarkdown(pr["body"]), false)
+		}
+		a.AddField("Reviewers", requestedReviewers(msg), true).
+			AddField("Labels", labelNames(pr), true)
+
+		return slack.NewMessage(msg.Channel()).Attach(a), nil
+	}
+}
+
+// repositoryFooter links to the repository of an event.
+func repositoryFooter(ec EventContext) string {
+	url, _ := ec.Get("repository.html_url").(string)
+	owner, _ := ec.Get("repository.owner.login").(string)
+	name, _ := ec.Get("repository.name").(string)
+	return slack.Link(url, owner+"/"+name)
+}
+
+// requestedReviewers links to the teams, then users, requested to review a
+// pull request.
+func requestedReviewers(ec EventContext) string {
+	org, _ := ec.Get("organization.login").(string)
+	var links []string
+	teams, _ := ec.Get("pull_request.requested_teams").([]any)
+	for _, t := range teams {
+		team, _ := t.(map[string]any)
+		url, _ := team["html_url"].(string)
+		slug, _ := team["slug"].(string)
+		links = append(links, slack.Link(url, "@"+org+"/"+slug))
+	}
+	users, _ := ec.Get("pull_request.requested_reviewers").([]any)
+	for _, u := range users {
+		user, _ := u.(map[string]any)
+		login, _ := user["login"].(string)
+		links = append(links, slack.Link("https://github.com/"+login, login))
+	}
+	return strings.Join(links, ", ")
+}
+
+func labelNames(pr map[string]any) string {
+	var names []string
+	labels, _ := pr["labels"].([]any)
+	for _, l := range labels {
+		label, _ := l.(map[string]any)
+		name, _ := label["name"].(string)
+		names = append(names, slack.Escape(name))
+	}
+	return strings.Join(names, ", ")
+}
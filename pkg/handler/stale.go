@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spaceweasel/slackhub/pkg/github"
+)
+
+const defaultStaleAfter = 48 * time.Hour
+
+type staleSummary struct {
+	// Count is the number of pull requests waiting on review.
+	Count int
+	// After is how long they've waited, at least.
+	After string
+	// Groups holds the pull requests by requested reviewer, oldest first,
+	// with those without a reviewer last.
+	Groups []reviewerGroup
+}
+
+type reviewerGroup struct {
+	// Reviewer is a login, or team as org/slug, empty if none is requested.
+	Reviewer string
+	// TeamURL links to the team, if the reviewer is one.
+	TeamURL string
+	Pulls   []stalePull
+}
+
+type stalePull struct {
+	Number int
+	Title  string
+	URL    string
+	Author string
+	Age    string
+}
+
+// summariseStale collects the open pull requests waiting on review for longer
+// than after, ignoring drafts, approved ones and those with any of the ignored
+// labels. Each reviewer's wait is from when their review was last requested,
+// or the pull request last became ready for review, whichever is later.
+func summariseStale(ctx context.Context, gh GitHub, repo, org string, now time.Time, after time.Duration, ignore map[string]bool) (staleSummary, error) {
+	pulls, err := gh.OpenPullRequests(ctx, repo)
+	if err != nil {
+		return staleSummary{}, fmt.Errorf("could not list pull requests, %w", err)
+	}
+
+	s := staleSummary{After: formatAge(after)}
+	groups := make(map[string]int)
+	add := func(reviewer, teamURL string, p stalePull) {
+		i, ok := groups[reviewer]
+		if !ok {
+			i = len(s.Groups)
+			groups[reviewer] = i
+			s.Groups = append(s.Groups, reviewerGroup{Reviewer: reviewer, TeamURL: teamURL})
+		}
+		s.Groups[i].Pulls = append(s.Groups[i].Pulls, p)
+	}
+
+	var unrequested []stalePull
+pulls:
+	for _, pr := range pulls {
+		// nothing has waited longer than the pull request has been open
+		if pr.Draft || now.Sub(pr.CreatedAt) < after {
+			continue
+		}
+		for _, l := range pr.Labels {
+			if ignore[l.Name] {
+				continue pulls
+			}
+		}
+
+		reviews, err := gh.Reviews(ctx, repo, pr.Number)
+		if err != nil {
+			return staleSummary{}, fmt.Errorf("could not list reviews of #%d, %w", pr.Number, err)
+		}
+		if approved(reviews) {
+			continue
+		}
+		events, err := gh.IssueEvents(ctx, repo, pr.Number)
+		if err != nil {
+			return staleSummary{}, fmt.Errorf("could not list events of #%d, %w", pr.Number, err)
+		}
+		w := waitingSince(pr, events)
+
+		stale := false
+		pull := func(since time.Time) (stalePull, bool) {
+			age := now.Sub(since)
+			if age < after {
+				return stalePull{}, false
+			}
+			stale = true
+			return stalePull{
+				Number: pr.Number,
+				Title:  pr.Title,
+				URL:    pr.HTMLURL,
+				Author: pr.User.Login,
+				Age:    formatAge(age),
+			}, true
+		}
+		if len(pr.RequestedReviewers) == 0 && len(pr.RequestedTeams) == 0 {
+			if p, ok := pull(w.ready); ok {
+				unrequested = append(unrequested, p)
+			}
+		}
+		for _, r := range pr.RequestedReviewers {
+			if p, ok := pull(w.since("user/" + r.Login)); ok {
+				add(r.Login, "", p)
+			}
+		}
+		for _, t := range pr.RequestedTeams {
+			if p, ok := pull(w.since("team/" + t.Slug)); ok {
+				add(org+"/"+t.Slug, t.HTMLURL, p)
+			}
+		}
+		if stale {
+			s.Count++
+		}
+	}
+	if len(unrequested) > 0 {
+		s.Groups = append(s.Groups, reviewerGroup{Pulls: unrequested})
+	}
+
+	return s, nil
+}
+
+// approved reports whether any reviewer's latest review approves.
+func approved(reviews []github.Review) bool {
+	latest := make(map[string]string)
+	for _, r := range reviews {
+		// comments leave an earlier approval standing
+		if r.State == "COMMENTED" {
+			continue
+		}
+		latest[r.User.Login] = r.State
+	}
+	for _, state := range latest {
+		if state == "APPROVED" {
+			return true
+		}
+	}
+	return false
+}
+
+// waiting holds when a pull request last became ready for review, and when
+// review was last requested of each user or team.
+type waiting struct {
+	ready     time.Time
+	requested map[string]time.Time
+}
+
+func waitingSince(pr github.PullRequest, events []github.IssueEvent) waiting {
+	w := waiting{ready: pr.CreatedAt, requested: make(map[string]time.Time)}
+	for _, e := range events {
+		switch {
+		case e.Event == "ready_for_review" && e.CreatedAt.After(w.ready):
+			w.ready = e.CreatedAt
+		case e.Event == "review_requested" && e.RequestedReviewer != nil:
+			w.requested["user/"+e.RequestedReviewer.Login] = e.CreatedAt
+		case e.Event == "review_requested" && e.RequestedTeam != nil:
+			w.requested["team/"+e.RequestedTeam.Slug] = e.CreatedAt
+		}
+	}
+	return w
+}
+
+// since returns when a reviewer, as user/login or team/slug, started waiting.
+func (w waiting) since(reviewer string) time.Time {
+	if t := w.requested[reviewer]; t.After(w.ready) {
+		return t
+	}
+	return w.ready
+}
+
+// formatAge describes a duration roughly, in days once more than one.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return plural(int(d.Minutes()), "minute")
+	case d < 48*time.Hour:
+		return plural(int(d.Hours()), "hour")
+	}
+	return plural(int(d.Hours()/24), "day")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
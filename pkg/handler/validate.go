@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Slack limits, beyond which messages are rejected or silently cut short.
+const (
+	maxTextLen       = 40000 // message text
+	maxBlocks        = 50
+	maxHeaderLen     = 150
+	maxSectionLen    = 3000
+	maxSectionFields = 10
+	maxFieldLen      = 2000
+	maxElements      = 10 // context block elements
+	maxAttachments   = 100
+	maxTitleLen      = 250
+	maxAttachmentLen = 3000 // attachment text, pretext and field values
+)
+
+// validate checks a rendered message against the Slack limits, truncating
+// text where it can be cut safely and returning an error where it can't.
+func validate(b []byte) ([]byte, error) {
+	var msg map[string]any
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return nil, jsonError(b, err)
+	}
+
+	v := &validator{}
+	v.truncate(msg, "text", maxTextLen, "")
+	if err := v.blocks(msg["blocks"], ""); err != nil {
+		return nil, err
+	}
+
+	attachments, _ := msg["attachments"].([]any)
+	if len(attachments) > maxAttachments {
+		return nil, fmt.Errorf("message has %d attachments, Slack allows at most %d", len(attachments), maxAttachments)
+	}
+	for _, a := range attachments {
+		att, ok := a.(map[string]any)
+		if !ok {
+			continue
+		}
+		link, _ := att["title_link"].(string)
+		v.truncate(att, "title", maxTitleLen, "")
+		v.truncate(att, "pretext", maxAttachmentLen, link)
+		v.truncate(att, "text", maxAttachmentLen, link)
+		fields, _ := att["fields"].([]any)
+		for _, f := range fields {
+			if field, ok := f.(map[string]any); ok {
+				v.truncate(field, "value", maxAttachmentLen, link)
+			}
+		}
+		if err := v.blocks(att["blocks"], link); err != nil {
+			return nil, err
+		}
+	}
+
+	return v.result(b, msg)
+}
+
+type validator struct {
+	changed bool
+}
+
+// result returns the original message unless anything was truncated.
+func (v *validator) result(b []byte, msg map[string]any) ([]byte, error) {
+	if !v.changed {
+		return b, nil
+	}
+
+	out := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(msg); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+func (v *validator) blocks(b any, link string) error {
+	blocks, _ := b.([]any)
+	if len(blocks) > maxBlocks {
+		return fmt.Errorf("message has %d blocks, Slack allows at most %d", len(blocks), maxBlocks)
+	}
+
+	for i, bl := range blocks {
+		block, ok := bl.(map[string]any)
+		if !ok {
+			continue
+		}
+		text, _ := block["text"].(map[string]any)
+		switch block["type"] {
+		case "header":
+			v.truncate(text, "text", maxHeaderLen, "")
+		case "section":
+			v.truncate(text, "text", maxSectionLen, link)
+			fields, _ := block["fields"].([]any)
+			if len(fields) > maxSectionFields {
+				return fmt.Errorf("block %d has %d fields, Slack allows at most %d", i, len(fields), maxSectionFields)
+			}
+			for _, f := range fields {
+				if field, ok := f.(map[string]any); ok {
+					v.truncate(field, "text", maxFieldLen, link)
+				}
+			}
+		case "context":
+			elements, _ := block["elements"].([]any)
+			if len(elements) > maxElements {
+				return fmt.Errorf("block %d has %d elements, Slack allows at most %d", i, len(elements), maxElements)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) truncate(m map[string]any, key string, max int, link string) {
+	s, ok := m[key].(string)
+	if !ok || utf8.RuneCountInString(s) <= max {
+		return
+	}
+	m[key] = truncate(s, max, link)
+	v.changed = true
+}
+
+// truncate shortens s to at most max characters, cutting at a line or word
+// boundary outside of any link, and closing any code block left open. The
+// text is ended with an ellipsis, linked to the full text if possible.
+func truncate(s string, max int, link string) string {
+	suffix := "…"
+	if link != "" {
+		suffix = "… <" + link + "|see more>"
+	}
+	// allow for closing a code block
+	budget := max - utf8.RuneCountInString(suffix) - len("\n```\n")
+	if budget <= 0 {
+		return string([]rune(s)[:max])
+	}
+
+	cut := string([]rune(s)[:budget])
+
+	// don't split a link or mention
+	if i := strings.LastIndex(cut, "<"); i > strings.LastIndex(cut, ">") {
+		cut = cut[:i]
+	}
+
+	// prefer a line boundary, then a word boundary, unless too much is lost
+	if i := strings.LastIndex(cut, "\n"); i > len(cut)/2 {
+		cut = cut[:i]
+	} else if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	cut = strings.TrimRight(cut, " \n")
+
+	if strings.Count(cut, "```")%2 == 1 {
+		cut += "\n```\n"
+	}
+
+	return cut + suffix
+}
+
+// jsonError describes where a message isn't valid JSON, which is usually
+// due to a template or an unescaped value.
+func jsonError(b []byte, err error) error {
+	var se *json.SyntaxError
+	if !errors.As(err, &se) {
+		return fmt.Errorf("message is not valid JSON, %w", err)
+	}
+
+	offset := int(se.Offset)
+	if offset > len(b) {
+		offset = len(b)
+	}
+	line := 1 + bytes.Count(b[:offset], []byte("\n"))
+	start := bytes.LastIndexByte(b[:offset], '\n') + 1
+	end := bytes.IndexByte(b[offset:], '\n')
+	if end < 0 {
+		end = len(b)
+	} else {
+		end += offset
+	}
+
+	return fmt.Errorf("message is not valid JSON at line %d, column %d: %w\n%s",
+		line, offset-start, err, strings.TrimSpace(string(b[start:end])))
+}
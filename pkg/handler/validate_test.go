@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestValidate(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("Unchanged when within limits", func(c *qt.C) {
+		in := []byte(`{"channel":"biscuits", "attachments":[{"title":"<eat>","text":"custard"}]}`)
+		out, err := validate(in)
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.DeepEquals, in)
+	})
+
+	c.Run("Long field truncated with link", func(c *qt.C) {
+		in, _ := json.Marshal(map[string]any{
+			"attachments": []any{map[string]any{
+				"title_link": "https://github.com/spaceweasel/jeff-test/pull/14",
+				"fields": []any{map[string]any{
+					"value": strings.Repeat("custard ", 1000),
+				}},
+			}},
+		})
+		out, err := validate(in)
+		c.Assert(err, qt.IsNil)
+
+		var msg struct {
+			Attachments []struct {
+				Fields []struct{ Value string }
+			}
+		}
+		c.Assert(json.Unmarshal(out, &msg), qt.IsNil)
+		value := msg.Attachments[0].Fields[0].Value
+		c.Assert(utf8.RuneCountInString(value) <= maxAttachmentLen, qt.IsTrue)
+		c.Assert(value, qt.Matches, `(custard )*custard… <https://github.com/spaceweasel/jeff-test/pull/14\|see more>`)
+	})
+
+	c.Run("Too many blocks", func(c *qt.C) {
+		blocks := make([]any, 51)
+		for i := range blocks {
+			blocks[i] = map[string]any{"type": "divider"}
+		}
+		in, _ := json.Marshal(map[string]any{"blocks": blocks})
+		_, err := validate(in)
+		c.Assert(err, qt.ErrorMatches, "message has 51 blocks, Slack allows at most 50")
+	})
+
+	c.Run("Too many section fields", func(c *qt.C) {
+		fields := make([]any, 11)
+		for i := range fields {
+			fields[i] = map[string]any{"type": "mrkdwn", "text": "x"}
+		}
+		in, _ := json.Marshal(map[string]any{"blocks": []any{map[string]any{"type": "section", "fields": fields}}})
+		_, err := validate(in)
+		c.Assert(err, qt.ErrorMatches, "block 0 has 11 fields, Slack allows at most 10")
+	})
+
+	c.Run("Invalid JSON", func(c *qt.C) {
+		in := []byte("{\n\t\"channel\":\"biscuits\",\n\t\"title\": \"A \"quoted\" title\"\n}")
+		_, err := validate(in)
+		c.Assert(err, qt.ErrorMatches, "(?s)message is not valid JSON at line 3, column 15: invalid character 'q' after object key:value pair\n\"title\": \"A \"quoted\" title\"")
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		link string
+		want string
+	}{
+		{
+			name: "Word boundary",
+			s:    "The quick brown fox jumps over the lazy dog",
+			max:  30,
+			want: "The quick brown fox…",
+		},
+		{
+			name: "Line boundary",
+			s:    "The quick brown\nfox jumps over the lazy dog",
+			max:  30,
+			want: "The quick brown…",
+		},
+		{
+			name: "Link not split",
+			s:    "See the fox <https://example.com/fox|here> jumping",
+			max:  40,
+			want: "See the fox…",
+		},
+		{
+			name: "Code block closed",
+			s:    "Try this:\n```\nfox.Jump()\nfox.Jump()\nfox.Jump()\n```",
+			max:  40,
+			want: "Try this:\n```\nfox.Jump()\n```\n…",
+		},
+		{
+			name: "Multibyte runes",
+			s:    strings.Repeat("🦊", 20),
+			max:  15,
+			want: strings.Repeat("🦊", 9) + "…",
+		},
+		{
+			name: "See more link",
+			s:    strings.Repeat("fox ", 20),
+			max:  40,
+			link: "https://x.io",
+			want: "fox fox… <https://x.io|see more>",
+		},
+	}
+
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			got := truncate(tt.s, tt.max, tt.link)
+			c.Assert(got, qt.Equals, tt.want)
+			c.Assert(utf8.RuneCountInString(got) <= tt.max, qt.IsTrue)
+		})
+	}
+}
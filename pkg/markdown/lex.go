@@ -342,6 +342,11 @@ loop:
 	if r == '\r' {
 		l.accept("\n")
 	}
+	// an empty block, such as a suggestion to remove lines
+	if strings.HasPrefix(l.input[l.pos:], codeBlock) {
+		l.emit(itemCode)
+		return lexCodeFinish
+	}
 
 	return lexCode
 }
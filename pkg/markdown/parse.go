@@ -2,24 +2,68 @@ package markdown
 
 import (
 	"fmt"
-	"log"
 	"runtime"
 	"strings"
 )
 
-// TODO: handle suggestions
-// if lang == suggestion, swap wih ```
+// Logger receives the tokens as they are parsed.
+type Logger interface {
+	Debugf(msg string, args ...any)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...any) {}
+
+type Option func(*parser)
+
+// WithLogger logs each token at debug level.
+func WithLogger(l Logger) Option {
+	return func(p *parser) {
+		p.log = l
+	}
+}
+
+// WithOriginal sets the code a review comment is on, which any suggested
+// change in the comment replaces.
+func WithOriginal(code string) Option {
+	return func(p *parser) {
+		p.original = code
+	}
+}
+
+// Parse parses the github markdown to construct a slack markdown representation,
+// with newlines and tabs escaped for a JSON string.
+func Parse(text string, opts ...Option) (string, error) {
+	smd, err := Text(text, opts...)
+	if err != nil {
+		return "", err
+	}
+	return Escape(smd), nil
+}
+
+// Escape escapes the newlines and tabs of slack markdown for a JSON string.
+func Escape(smd string) string {
+	return jsonEscaper.Replace(smd)
+}
+
+var jsonEscaper = strings.NewReplacer("\n", "\\n", "\t", "\\t")
 
-// Parse parses the github markdown to construct a slack markdown representation.
-func Parse(text string) (smd string, err error) {
+// Text converts github markdown to slack markdown, as Parse does, but without
+// escaping it.
+func Text(text string, opts ...Option) (smd string, err error) {
 	p := &parser{
 		lex: lex(text),
+		log: nopLogger{},
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	defer p.recover(&err)
 	p.parse()
 
-	return strings.ReplaceAll(p.text, "\t", "\\t"), nil
+	return p.text, nil
 }
 
 type parser struct {
@@ -27,6 +71,10 @@ type parser struct {
 	token     [2]item // two-token lookahead for parser.
 	peekCount int
 	text      string
+	log       Logger
+	// lang is the language of the current code block
+	lang     string
+	original string
 }
 
 // next returns the next token.
@@ -36,7 +84,7 @@ func (p *parser) next() item {
 	} else {
 		p.token[0] = p.lex.nextItem()
 	}
-	log.Println(p.token[p.peekCount])
+	p.log.Debugf("markdown token: %v", p.token[p.peekCount])
 	return p.token[p.peekCount]
 }
 
@@ -64,7 +112,7 @@ func (p *parser) parse() {
 		case itemEOF:
 			return
 		case itemEOL:
-			p.text += "\\n"
+			p.text += "\n"
 		case itemEsc:
 			// TODO: There has to be a way to deal with escaping in Slack
 			p.text += n.val
@@ -98,16 +146,12 @@ func (p *parser) parse() {
 			}
 		case itemBullet:
 			p.text += "• "
-		case itemCodeStart:
-			p.text += n.val
-		case itemCodeFinish:
-			p.text += n.val
+		case itemCodeStart, itemCodeFinish:
+			// fenced by parseCode
 		case itemCodeLang:
-			// ignore
+			p.lang = strings.ToLower(strings.TrimSpace(n.val))
 		case itemCode:
-			code := strings.ReplaceAll(n.val, "\n", "\\n")
-			code = strings.ReplaceAll(code, "\r", "")
-			p.text += code
+			p.text += strings.ReplaceAll(p.parseCode(n.val), "\r", "")
 
 		default:
 			p.errorf("unexpected %s", n)
@@ -126,7 +170,7 @@ func (p *parser) parseHeader() {
 			return
 		case itemEOL:
 			p.text += "*"
-			p.text += "\\n"
+			p.text += "\n"
 			return
 		case itemEsc:
 			next := p.peek()
@@ -192,6 +236,39 @@ func (p *parser) parseLink() {
 	p.text += fmt.Sprintf("<%s|%s>", url, text)
 }
 
+// parseCode fences a code block, labelling those GitHub renders specially.
+// The code starts and ends with a newline.
+func (p *parser) parseCode(code string) string {
+	const fence = "```"
+
+	switch p.lang {
+	case "suggestion":
+		if p.original == "" {
+			return "*Suggested change*\n" + fence + code + fence
+		}
+		// shown as a diff, replacing the lines commented on
+		var b strings.Builder
+		b.WriteString("*Suggested change*\n" + fence + "\n")
+		for _, l := range strings.Split(p.original, "\n") {
+			b.WriteString("-" + l + "\n")
+		}
+		if suggested := strings.Trim(code, "\r\n"); suggested != "" {
+			for _, l := range strings.Split(suggested, "\n") {
+				b.WriteString("+" + l + "\n")
+			}
+		}
+		b.WriteString(fence)
+		return b.String()
+	case "mermaid":
+		return "_Mermaid diagram, view it on GitHub_"
+	case "math":
+		return "*Math*\n" + fence + code + fence
+	}
+
+	// diffs keep their + and - markers, as does any other code
+	return fence + code + fence
+}
+
 func (p *parser) parseX() {
 
 }
@@ -131,9 +131,47 @@ followed by another.`,
 		parseTest("Use\n\tthis:\n```go\ntype Eater interface{\n\tEat()\n}\n```\ninstead.",
 			"Use\\n\\tthis:\\n```\\ntype Eater interface{\\n\\tEat()\\n}\\n```\\ninstead.", ""))
 
+	c.Run("Suggested change",
+		parseTest("Try:\n```suggestion\ntype Consumer interface{\n```\n",
+			"Try:\\n*Suggested change*\\n```\\ntype Consumer interface{\\n```\\n", ""))
+
+	c.Run("Diff code block",
+		parseTest("```diff\n-type Eater interface{\n+type Consumer interface{\n```",
+			"```\\n-type Eater interface{\\n+type Consumer interface{\\n```", ""))
+
+	c.Run("Mermaid code block",
+		parseTest("```mermaid\ngraph TD;\n  A-->B;\n```",
+			"_Mermaid diagram, view it on GitHub_", ""))
+
+	c.Run("Math code block",
+		parseTest("```math\n\\sqrt{3}\n```",
+			"*Math*\\n```\\n\\sqrt{3}\\n```", ""))
+
 	c.Run("With icons",
 		parseTest("## 💬 What does this PR do and why is this needed?\r\nThis PR eats all the custard\r\n\r\n## 📝 Describe the important code changes\r\n\r\n## ❔ Questions or remarks",
 			"**💬 What does this PR do and why is this needed?**\\nThis PR eats all the custard\\n\\n**📝 Describe the important code changes**\\n\\n**❔ Questions or remarks**", ""))
 
 	//
 }
+
+func TestParse_WithOriginal(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("Suggested change", func(c *qt.C) {
+		smd, err := Parse("```suggestion\ntype Consumer interface {\n\tConsume()\n```", WithOriginal("type Eater interface {"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(smd, qt.Equals, "*Suggested change*\\n```\\n-type Eater interface {\\n+type Consumer interface {\\n+\\tConsume()\\n```")
+	})
+
+	c.Run("Lines removed", func(c *qt.C) {
+		smd, err := Parse("```suggestion\n```", WithOriginal("\tFinish()"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(smd, qt.Equals, "*Suggested change*\\n```\\n-\\tFinish()\\n```")
+	})
+
+	c.Run("Other code", func(c *qt.C) {
+		smd, err := Parse("```go\nConsume()\n```", WithOriginal("Eat()"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(smd, qt.Equals, "```\\nConsume()\\n```")
+	})
+}
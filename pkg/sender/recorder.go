@@ -0,0 +1,59 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Recorder is the part of the GitHub Actions toolkit used to record messages.
+type Recorder interface {
+	Group(title string)
+	EndGroup()
+	Infof(msg string, args ...any)
+	AddStepSummary(markdown string)
+}
+
+// RecordingPoster logs messages to the workflow and job summary instead of
+// posting them to Slack, for dry runs.
+type RecordingPoster struct {
+	r Recorder
+}
+
+func NewRecordingPoster(r Recorder) *RecordingPoster {
+	return &RecordingPoster{
+		r: r,
+	}
+}
+
+func (p *RecordingPoster) Post(ctx context.Context, reader io.Reader) error {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	out, err := indent(b)
+	if err != nil {
+		p.r.Group("Invalid Slack message")
+		p.r.Infof("%s", b)
+		p.r.EndGroup()
+		return errors.New("message is not valid JSON, " + err.Error())
+	}
+
+	p.r.Group("Slack message (dry run)")
+	p.r.Infof("%s", out)
+	p.r.EndGroup()
+	p.r.AddStepSummary("### Slack message (dry run)\n\n```json\n" + string(out) + "\n```\n")
+
+	return nil
+}
+
+func indent(b []byte) ([]byte, error) {
+	out := bytes.NewBuffer(nil)
+	if err := json.Indent(out, b, "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
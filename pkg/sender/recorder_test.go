@@ -0,0 +1,65 @@
+package sender_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/spaceweasel/slackhub/pkg/sender"
+)
+
+func TestRecordingPoster_Post(t *testing.T) {
+	c := qt.New(t)
+
+	r := &fakeRecorder{}
+	p := sender.NewRecordingPoster(r)
+
+	err := p.Post(context.Background(), strings.NewReader(`{"channel":"biscuits","text":"hi"}`))
+	c.Assert(err, qt.IsNil)
+	c.Assert(r.log, qt.DeepEquals, []string{
+		"group: Slack message (dry run)",
+		"{\n  \"channel\": \"biscuits\",\n  \"text\": \"hi\"\n}",
+		"endgroup",
+	})
+	c.Assert(r.summary, qt.Equals, "### Slack message (dry run)\n\n```json\n{\n  \"channel\": \"biscuits\",\n  \"text\": \"hi\"\n}\n```\n")
+}
+
+func TestRecordingPoster_PostInvalid(t *testing.T) {
+	c := qt.New(t)
+
+	r := &fakeRecorder{}
+	p := sender.NewRecordingPoster(r)
+
+	err := p.Post(context.Background(), strings.NewReader(`{"text":"a "quoted" title"}`))
+	c.Assert(err, qt.ErrorMatches, "message is not valid JSON, .*")
+	c.Assert(r.log, qt.DeepEquals, []string{
+		"group: Invalid Slack message",
+		`{"text":"a "quoted" title"}`,
+		"endgroup",
+	})
+	c.Assert(r.summary, qt.Equals, "")
+}
+
+type fakeRecorder struct {
+	log     []string
+	summary string
+}
+
+func (r *fakeRecorder) Group(title string) {
+	r.log = append(r.log, "group: "+title)
+}
+
+func (r *fakeRecorder) EndGroup() {
+	r.log = append(r.log, "endgroup")
+}
+
+func (r *fakeRecorder) Infof(msg string, args ...any) {
+	r.log = append(r.log, fmt.Sprintf(msg, args...))
+}
+
+func (r *fakeRecorder) AddStepSummary(markdown string) {
+	r.summary += markdown
+}
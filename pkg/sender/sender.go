@@ -1,11 +1,21 @@
 package sender
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/spaceweasel/slackhub/pkg/store"
 )
 
 type MessagePoster interface {
@@ -13,60 +23,316 @@ type MessagePoster interface {
 }
 
 type Poster struct {
-	hc    *http.Client
-	token string
+	hc      *http.Client
+	baseURL string
+	token   string
+	log     Logger
+}
+
+const defaultBaseURL = "https://slack.com/api"
+
+type options struct {
+	hc      *http.Client
+	baseURL string
+	roots   *x509.CertPool
+	log     Logger
+}
+
+// Logger is satisfied by the action logger.
+type Logger interface {
+	Debugf(msg string, args ...any)
+	Warningf(msg string, args ...any)
 }
 
-type Option func(*Poster)
+type nopLogger struct{}
 
+func (nopLogger) Debugf(string, ...any)   {}
+func (nopLogger) Warningf(string, ...any) {}
+
+// WithLogger logs requests and responses at debug level, and any warnings
+// returned by Slack.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.log = l
+	}
+}
+
+type Option func(*options)
+
+// WithHTTPClient replaces the HTTP client, ignoring any root CAs.
 func WithHTTPClient(hc *http.Client) Option {
-	return func(p *Poster) {
-		p.hc = hc
+	return func(o *options) {
+		o.hc = hc
 	}
 }
 
-func NewPoster(token string, opts ...Option) *Poster {
-	p := &Poster{
-		hc: &http.Client{
-			Timeout: 15 * time.Second,
-		},
-		token: token,
+// WithBaseURL sets the Slack API URL, e.g. for GovSlack or a local stand-in.
+func WithBaseURL(u string) Option {
+	return func(o *options) {
+		if u != "" {
+			o.baseURL = strings.TrimSuffix(u, "/")
+		}
+	}
+}
+
+// WithRootCAs sets the certificate authorities trusted when connecting, such
+// as those of a proxy intercepting TLS.
+func WithRootCAs(roots *x509.CertPool) Option {
+	return func(o *options) {
+		o.roots = roots
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		baseURL: defaultBaseURL,
+		log:     nopLogger{},
 	}
 
 	for _, opt := range opts {
-		opt(p)
+		opt(o)
+	}
+
+	if o.hc == nil {
+		// the default transport honours HTTPS_PROXY and NO_PROXY
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if o.roots != nil {
+			t.TLSClientConfig = &tls.Config{RootCAs: o.roots}
+		}
+		o.hc = &http.Client{
+			Transport: t,
+			Timeout:   15 * time.Second,
+		}
+	}
+
+	return o
+}
+
+func NewPoster(token string, opts ...Option) *Poster {
+	o := newOptions(opts)
+	p := &Poster{
+		hc:      o.hc,
+		baseURL: o.baseURL,
+		token:   token,
+		log:     o.log,
 	}
 
 	return p
 }
 
 func (p *Poster) Post(ctx context.Context, reader io.Reader) error {
-	const url = "https://slack.com/api/chat.postMessage"
+	_, err := p.PostRef(ctx, reader)
+	return err
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, reader)
+// PostRef posts a message, returning a reference to it.
+func (p *Poster) PostRef(ctx context.Context, reader io.Reader) (store.Ref, error) {
+	var ref store.Ref
+	err := p.call(ctx, "chat.postMessage", reader, &ref)
+	return ref, err
+}
+
+// Update replaces a posted message, such as to coalesce a burst of events into
+// it.
+func (p *Poster) Update(ctx context.Context, ref store.Ref, reader io.Reader) error {
+	var msg map[string]any
+	if err := json.NewDecoder(reader).Decode(&msg); err != nil {
+		return fmt.Errorf("could not decode message, %w", err)
+	}
+	msg["channel"] = ref.Channel
+	msg["ts"] = ref.TS
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return p.call(ctx, "chat.update", bytes.NewReader(body), nil)
+}
+
+// AddReaction reacts to a posted message with an emoji, given by name, e.g.
+// white_check_mark. Reacting again does nothing.
+func (p *Poster) AddReaction(ctx context.Context, ref store.Ref, name string) error {
+	err := p.react(ctx, "reactions.add", ref, name)
+	if e := (*Error)(nil); errors.As(err, &e) && e.Code == "already_reacted" {
+		return nil
+	}
+	return err
+}
+
+// RemoveReaction removes a reaction from a posted message, doing nothing if
+// it hasn't been reacted to with the emoji.
+func (p *Poster) RemoveReaction(ctx context.Context, ref store.Ref, name string) error {
+	err := p.react(ctx, "reactions.remove", ref, name)
+	if e := (*Error)(nil); errors.As(err, &e) && e.Code == "no_reaction" {
+		return nil
+	}
+	return err
+}
+
+func (p *Poster) react(ctx context.Context, method string, ref store.Ref, name string) error {
+	body, err := json.Marshal(map[string]string{
+		"channel":   ref.Channel,
+		"timestamp": ref.TS,
+		"name":      name,
+	})
+	if err != nil {
+		return err
+	}
+	return p.call(ctx, method, bytes.NewReader(body), nil)
+}
+
+// maxHistory limits how many messages are searched.
+const maxHistory = 1000
+
+// History lists the messages posted to a channel since a time, newest first,
+// including their metadata.
+func (p *Poster) History(ctx context.Context, channel string, since time.Time) ([]store.Message, error) {
+	var msgs []store.Message
+	cursor := ""
+	for len(msgs) < maxHistory {
+		q := url.Values{
+			"channel":              {channel},
+			"oldest":               {strconv.FormatInt(since.Unix(), 10)},
+			"include_all_metadata": {"true"},
+			"limit":                {"200"},
+		}
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/conversations.history?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var r struct {
+			Messages         []store.Message `json:"messages"`
+			HasMore          bool            `json:"has_more"`
+			ResponseMetadata struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"response_metadata"`
+		}
+		if err := p.do(req, "conversations.history", &r); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, r.Messages...)
+		if !r.HasMore || r.ResponseMetadata.NextCursor == "" {
+			break
+		}
+		cursor = r.ResponseMetadata.NextCursor
+	}
+
+	return msgs, nil
+}
+
+// OpenConversation opens a direct message with a Slack user, returning the
+// channel ID to post to.
+func (p *Poster) OpenConversation(ctx context.Context, user string) (string, error) {
+	body, err := json.Marshal(map[string]string{"users": user})
+	if err != nil {
+		return "", err
+	}
+
+	var r struct {
+		Channel struct {
+			ID string `json:"id"`
+		} `json:"channel"`
+	}
+	if err := p.call(ctx, "conversations.open", bytes.NewReader(body), &r); err != nil {
+		return "", err
+	}
+
+	return r.Channel.ID, nil
+}
+
+// call posts JSON to a Slack API method, decoding the response into v if
+// given.
+func (p *Poster) call(ctx context.Context, method string, reader io.Reader, v any) error {
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/"+method, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+p.token)
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
+	return p.do(req, method, v)
+}
+
+// do sends a request to a Slack API method, decoding the response into v if
+// given. Requests and responses are logged at debug level, and any warnings
+// from Slack logged as warnings.
+func (p *Poster) do(req *http.Request, method string, v any) error {
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	if req.GetBody != nil {
+		body, _ := req.GetBody()
+		b, _ := io.ReadAll(body)
+		p.log.Debugf("%s %s (token %s)\n%s", req.Method, req.URL, redact(p.token), b)
+	} else {
+		p.log.Debugf("%s %s (token %s)", req.Method, req.URL, redact(p.token))
+	}
+
 	resp, err := p.hc.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+
+	rb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read %s response, %w", method, err)
+	}
+	p.log.Debugf("%s response %s\n%s", method, resp.Status, rb)
+
 	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Status response:", resp.StatusCode, resp.Status)
-		return fmt.Errorf("bad code")
+		return fmt.Errorf("%s failed with %d", method, resp.StatusCode)
 	}
 
-	rb, err := io.ReadAll(resp.Body)
-	fmt.Println("Response:", string(rb), err)
+	var r struct {
+		OK               bool   `json:"ok"`
+		Error            string `json:"error"`
+		ResponseMetadata struct {
+			Warnings []string `json:"warnings"`
+			Messages []string `json:"messages"`
+		} `json:"response_metadata"`
+	}
+	if err := json.Unmarshal(rb, &r); err != nil {
+		return fmt.Errorf("%s response not understood, %w", method, err)
+	}
+	for _, w := range r.ResponseMetadata.Warnings {
+		p.log.Warningf("%s warning, %s", method, w)
+	}
+	if !r.OK {
+		return &Error{Method: method, Code: r.Error, Messages: r.ResponseMetadata.Messages}
+	}
 
-	// TODO: check for error response
-	// {
-	//     "ok": false,
-	//     "error": "too_many_attachments"
-	// }
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(rb, v)
+}
 
-	return nil
+// Error is returned when a Slack API method fails.
+type Error struct {
+	Method string
+	// Code is the error returned by Slack, e.g. channel_not_found.
+	Code     string
+	Messages []string
+}
+
+func (e *Error) Error() string {
+	if len(e.Messages) > 0 {
+		return fmt.Sprintf("%s failed, %s: %s", e.Method, e.Code, strings.Join(e.Messages, ", "))
+	}
+	return fmt.Sprintf("%s failed, %s", e.Method, e.Code)
+}
+
+// redact hides all but the type of a token, e.g. xoxb-***.
+func redact(token string) string {
+	if typ, _, ok := strings.Cut(token, "-"); ok {
+		return typ + "-***"
+	}
+	return "***"
 }
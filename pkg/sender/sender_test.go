@@ -0,0 +1,180 @@
+package sender_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/spaceweasel/slackhub/pkg/sender"
+	"github.com/spaceweasel/slackhub/pkg/store"
+)
+
+func TestPoster_Post(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/api/chat.postMessage")
+		c.Check(r.Header.Get("Authorization"), qt.Equals, "Bearer xoxb-biscuits")
+		b, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(b), "missing") {
+			io.WriteString(w, `{"ok":false,"error":"channel_not_found"}`)
+			return
+		}
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	p := sender.NewPoster("xoxb-biscuits", sender.WithBaseURL(srv.URL+"/api/"))
+
+	err := p.Post(context.Background(), strings.NewReader(`{"channel":"biscuits","text":"hi"}`))
+	c.Assert(err, qt.IsNil)
+
+	err = p.Post(context.Background(), strings.NewReader(`{"channel":"missing","text":"hi"}`))
+	c.Assert(err, qt.ErrorMatches, "chat.postMessage failed, channel_not_found")
+}
+
+func TestPoster_PostRootCAs(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	// the test server certificate isn't trusted by default
+	p := sender.NewPoster("xoxb-biscuits", sender.WithBaseURL(srv.URL))
+	err := p.Post(context.Background(), strings.NewReader(`{"text":"hi"}`))
+	c.Assert(err, qt.ErrorMatches, ".*certificate.*")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	p = sender.NewPoster("xoxb-biscuits", sender.WithBaseURL(srv.URL), sender.WithRootCAs(roots))
+	err = p.Post(context.Background(), strings.NewReader(`{"text":"hi"}`))
+	c.Assert(err, qt.IsNil)
+}
+
+type fakeLogger struct {
+	debug, warnings []string
+}
+
+func (l *fakeLogger) Debugf(msg string, args ...any) {
+	l.debug = append(l.debug, fmt.Sprintf(msg, args...))
+}
+
+func (l *fakeLogger) Warningf(msg string, args ...any) {
+	l.warnings = append(l.warnings, fmt.Sprintf(msg, args...))
+}
+
+func TestPoster_PostLogging(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"ok":true,"warning":"missing_charset","response_metadata":{"warnings":["missing_charset"]}}`)
+	}))
+	defer srv.Close()
+
+	l := &fakeLogger{}
+	p := sender.NewPoster("xoxb-biscuits", sender.WithBaseURL(srv.URL), sender.WithLogger(l))
+	err := p.Post(context.Background(), strings.NewReader(`{"text":"hi"}`))
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(l.debug, qt.HasLen, 2)
+	c.Assert(l.debug[0], qt.Equals, "POST "+srv.URL+"/chat.postMessage (token xoxb-***)\n{\"text\":\"hi\"}")
+	c.Assert(strings.Join(l.debug, "\n"), qt.Not(qt.Contains), "biscuits")
+	c.Assert(l.warnings, qt.DeepEquals, []string{"chat.postMessage warning, missing_charset"})
+}
+
+func TestPoster_History(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/conversations.history")
+		c.Check(r.URL.Query().Get("channel"), qt.Equals, "C0123")
+		c.Check(r.URL.Query().Get("oldest"), qt.Equals, "1662459000")
+		c.Check(r.URL.Query().Get("include_all_metadata"), qt.Equals, "true")
+		if r.URL.Query().Get("cursor") == "" {
+			io.WriteString(w, `{"ok":true,"messages":[{"ts":"1662459000.000200","metadata":{"event_type":"slackhub_ref","event_payload":{"keys":["custard"]}}}],"has_more":true,"response_metadata":{"next_cursor":"bmV4dA=="}}`)
+			return
+		}
+		io.WriteString(w, `{"ok":true,"messages":[{"ts":"1662459000.000100"}],"has_more":false}`)
+	}))
+	defer srv.Close()
+
+	p := sender.NewPoster("xoxb-biscuits", sender.WithBaseURL(srv.URL))
+	msgs, err := p.History(context.Background(), "C0123", time.Unix(1662459000, 0))
+	c.Assert(err, qt.IsNil)
+	c.Assert(msgs, qt.HasLen, 2)
+	c.Assert(msgs[0].TS, qt.Equals, "1662459000.000200")
+	c.Assert(msgs[0].Metadata.EventPayload["keys"], qt.DeepEquals, []any{"custard"})
+	c.Assert(msgs[1].TS, qt.Equals, "1662459000.000100")
+}
+
+func TestPoster_Update(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/chat.update")
+		var msg map[string]any
+		c.Check(json.NewDecoder(r.Body).Decode(&msg), qt.IsNil)
+		c.Check(msg, qt.DeepEquals, map[string]any{
+			"channel": "C123",
+			"ts":      "1662454800.000100",
+			"text":    "hi again",
+		})
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	p := sender.NewPoster("xoxb-biscuits", sender.WithBaseURL(srv.URL))
+	ref := store.Ref{Channel: "C123", TS: "1662454800.000100"}
+	err := p.Update(context.Background(), ref, strings.NewReader(`{"channel":"biscuits","text":"hi again"}`))
+	c.Assert(err, qt.IsNil)
+}
+
+func TestPoster_Reactions(t *testing.T) {
+	c := qt.New(t)
+
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		c.Check(json.NewDecoder(r.Body).Decode(&body), qt.IsNil)
+		c.Check(body["channel"], qt.Equals, "C123")
+		c.Check(body["timestamp"], qt.Equals, "1662454800.000100")
+		calls = append(calls, r.URL.Path+" "+body["name"])
+		switch body["name"] {
+		case "repeat":
+			io.WriteString(w, `{"ok":false,"error":"already_reacted"}`)
+		case "no_entry_sign":
+			io.WriteString(w, `{"ok":false,"error":"no_reaction"}`)
+		case "custard":
+			io.WriteString(w, `{"ok":false,"error":"invalid_name"}`)
+		default:
+			io.WriteString(w, `{"ok":true}`)
+		}
+	}))
+	defer srv.Close()
+
+	p := sender.NewPoster("xoxb-biscuits", sender.WithBaseURL(srv.URL))
+	ctx := context.Background()
+	ref := store.Ref{Channel: "C123", TS: "1662454800.000100"}
+
+	c.Assert(p.AddReaction(ctx, ref, "white_check_mark"), qt.IsNil)
+	c.Assert(p.AddReaction(ctx, ref, "repeat"), qt.IsNil)
+	c.Assert(p.RemoveReaction(ctx, ref, "no_entry_sign"), qt.IsNil)
+	c.Assert(p.AddReaction(ctx, ref, "custard"), qt.ErrorMatches, "reactions.add failed, invalid_name")
+	c.Assert(calls, qt.DeepEquals, []string{
+		"/reactions.add white_check_mark",
+		"/reactions.add repeat",
+		"/reactions.remove no_entry_sign",
+		"/reactions.add custard",
+	})
+}
@@ -0,0 +1,79 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WebhookPoster posts messages to an incoming webhook, such as those of
+// Slack, Teams and Discord, which are bound to a single channel.
+type WebhookPoster struct {
+	hc          *http.Client
+	url         string
+	keepChannel bool
+	log         Logger
+}
+
+func NewWebhookPoster(url string, opts ...Option) *WebhookPoster {
+	o := newOptions(opts)
+	return &WebhookPoster{
+		hc:  o.hc,
+		url: url,
+		log: o.log,
+	}
+}
+
+// NewMattermostPoster returns a poster for a Mattermost incoming webhook,
+// which accepts Slack messages and allows the channel to be chosen.
+func NewMattermostPoster(url string, opts ...Option) *WebhookPoster {
+	p := NewWebhookPoster(url, opts...)
+	p.keepChannel = true
+	return p
+}
+
+func (p *WebhookPoster) Post(ctx context.Context, reader io.Reader) error {
+	var msg map[string]json.RawMessage
+	if err := json.NewDecoder(reader).Decode(&msg); err != nil {
+		return fmt.Errorf("message is not valid JSON, %w", err)
+	}
+	// webhooks post to their own channel
+	if !p.keepChannel {
+		delete(msg, "channel")
+	}
+
+	body := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(body)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(msg); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	// the webhook URL is a secret
+	p.log.Debugf("POST %s/***\n%s", req.URL.Host, body)
+
+	resp, err := p.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Slack webhooks respond in plain text, e.g. 404 channel_not_found,
+	// while Discord responds with 204 No Content
+	rb, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	p.log.Debugf("webhook response %s\n%s", resp.Status, rb)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook failed with %d, %s", resp.StatusCode, strings.TrimSpace(string(rb)))
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package sender_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/spaceweasel/slackhub/pkg/sender"
+)
+
+func TestWebhookPoster_Post(t *testing.T) {
+	c := qt.New(t)
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+		if strings.Contains(got, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "channel_not_found")
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	p := sender.NewWebhookPoster(srv.URL, sender.WithHTTPClient(srv.Client()))
+
+	err := p.Post(context.Background(), strings.NewReader(`{"channel":"biscuits","text":"<a|b> & c"}`))
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, "{\"text\":\"<a|b> & c\"}\n")
+
+	err = p.Post(context.Background(), strings.NewReader(`{"text":"missing"}`))
+	c.Assert(err, qt.ErrorMatches, "webhook failed with 404, channel_not_found")
+
+	err = p.Post(context.Background(), strings.NewReader(`{"text":`))
+	c.Assert(err, qt.ErrorMatches, "message is not valid JSON, .*")
+
+	p = sender.NewMattermostPoster(srv.URL, sender.WithHTTPClient(srv.Client()))
+	err = p.Post(context.Background(), strings.NewReader(`{"channel":"biscuits","text":"hi"}`))
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, "{\"channel\":\"biscuits\",\"text\":\"hi\"}\n")
+}
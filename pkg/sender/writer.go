@@ -0,0 +1,35 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// WriterPoster writes messages to a writer instead of posting them to Slack.
+type WriterPoster struct {
+	w io.Writer
+}
+
+func NewWriterPoster(w io.Writer) *WriterPoster {
+	return &WriterPoster{
+		w: w,
+	}
+}
+
+// Post writes the message indented, or as is if it isn't valid JSON.
+func (p *WriterPoster) Post(ctx context.Context, reader io.Reader) error {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	out, err := indent(b)
+	if err != nil {
+		p.w.Write(append(b, '\n'))
+		return errors.New("message is not valid JSON, " + err.Error())
+	}
+
+	_, err = p.w.Write(append(out, '\n'))
+	return err
+}
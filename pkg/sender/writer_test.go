@@ -0,0 +1,28 @@
+package sender_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/spaceweasel/slackhub/pkg/sender"
+)
+
+func TestWriterPoster_Post(t *testing.T) {
+	c := qt.New(t)
+
+	out := bytes.NewBuffer(nil)
+	p := sender.NewWriterPoster(out)
+
+	err := p.Post(context.Background(), strings.NewReader(`{"channel":"biscuits",  "text":"hi"}`))
+	c.Assert(err, qt.IsNil)
+	c.Assert(out.String(), qt.Equals, "{\n  \"channel\": \"biscuits\",\n  \"text\": \"hi\"\n}\n")
+
+	out.Reset()
+	err = p.Post(context.Background(), strings.NewReader(`{"channel":"biscuits",}`))
+	c.Assert(err, qt.ErrorMatches, "message is not valid JSON, .*")
+	c.Assert(out.String(), qt.Equals, "{\"channel\":\"biscuits\",}\n")
+}
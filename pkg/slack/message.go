@@ -0,0 +1,157 @@
+// Package slack models Slack messages, so that they marshal to valid JSON
+// whatever text they hold.
+package slack
+
+import "strings"
+
+// DefaultFooterIcon is shown beside attachment footers.
+const DefaultFooterIcon = "https://platform.slack-edge.com/img/default_application_icon.png"
+
+// Message is posted with chat.postMessage.
+type Message struct {
+	Channel     string        `json:"channel"`
+	Text        string        `json:"text,omitempty"`
+	Blocks      []Block       `json:"blocks,omitempty"`
+	Attachments []*Attachment `json:"attachments,omitempty"`
+}
+
+// NewMessage returns an empty message for a channel.
+func NewMessage(channel string) *Message {
+	return &Message{Channel: channel}
+}
+
+// Attach adds an attachment to the message.
+func (m *Message) Attach(a *Attachment) *Message {
+	m.Attachments = append(m.Attachments, a)
+	return m
+}
+
+// AddBlock adds a block to the message.
+func (m *Message) AddBlock(b Block) *Message {
+	m.Blocks = append(m.Blocks, b)
+	return m
+}
+
+// Attachment is a legacy attachment, shown with a coloured bar beside it.
+// Pretext, title and text are always sent, even if empty.
+type Attachment struct {
+	MrkdwnIn   []string `json:"mrkdwn_in,omitempty"`
+	Color      string   `json:"color,omitempty"`
+	Pretext    string   `json:"pretext"`
+	Title      string   `json:"title"`
+	TitleLink  string   `json:"title_link"`
+	Text       string   `json:"text"`
+	Fields     []Field  `json:"fields,omitempty"`
+	Blocks     []Block  `json:"blocks,omitempty"`
+	Footer     string   `json:"footer,omitempty"`
+	FooterIcon string   `json:"footer_icon,omitempty"`
+	TS         int64    `json:"ts,omitempty"`
+}
+
+// NewAttachment returns an attachment formatting its pretext, text and fields
+// as mrkdwn.
+func NewAttachment(color string) *Attachment {
+	return &Attachment{
+		MrkdwnIn: []string{"text", "pretext", "fields"},
+		Color:    color,
+	}
+}
+
+// AddField adds a field, shown beside the next if both are short.
+func (a *Attachment) AddField(title, value string, short bool) *Attachment {
+	a.Fields = append(a.Fields, Field{Title: title, Value: value, Short: short})
+	return a
+}
+
+// WithTitle sets the title, linked to url.
+func (a *Attachment) WithTitle(title, url string) *Attachment {
+	a.Title = title
+	a.TitleLink = url
+	return a
+}
+
+// WithFooter sets the footer, shown with the default icon, and the time shown
+// beside it.
+func (a *Attachment) WithFooter(footer string, ts int64) *Attachment {
+	a.Footer = footer
+	a.FooterIcon = DefaultFooterIcon
+	a.TS = ts
+	return a
+}
+
+// Field is a titled value within an attachment.
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// Block is a Block Kit layout block, such as a section.
+type Block struct {
+	Type     string    `json:"type"`
+	Text     *Text     `json:"text,omitempty"`
+	Fields   []Text    `json:"fields,omitempty"`
+	Elements []Element `json:"elements,omitempty"`
+}
+
+// Section returns a section block of mrkdwn text.
+func Section(text string) Block {
+	return Block{Type: "section", Text: Markdown(text)}
+}
+
+// Header returns a header block of plain text.
+func Header(text string) Block {
+	return Block{Type: "header", Text: &Text{Type: "plain_text", Text: text}}
+}
+
+// Context returns a context block, shown in small type.
+func Context(elements ...Element) Block {
+	return Block{Type: "context", Elements: elements}
+}
+
+// Divider returns a block separating those either side of it.
+func Divider() Block {
+	return Block{Type: "divider"}
+}
+
+// Text is a text object, either plain_text or mrkdwn.
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Markdown returns a mrkdwn text object.
+func Markdown(text string) *Text {
+	return &Text{Type: "mrkdwn", Text: text}
+}
+
+// Element is an element of a context block, either text or an image.
+type Element struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+}
+
+// MarkdownElement returns a mrkdwn text element.
+func MarkdownElement(text string) Element {
+	return Element{Type: "mrkdwn", Text: text}
+}
+
+// ImageElement returns an image element.
+func ImageElement(url, alt string) Element {
+	return Element{Type: "image", ImageURL: url, AltText: alt}
+}
+
+// Link formats a link to url in mrkdwn, escaping the text.
+func Link(url, text string) string {
+	return "<" + url + "|" + Escape(text) + ">"
+}
+
+// Escape escapes the characters Slack treats as control characters in
+// mrkdwn.
+func Escape(text string) string {
+	return escaper.Replace(text)
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
@@ -0,0 +1,51 @@
+package slack_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/spaceweasel/slackhub/pkg/slack"
+)
+
+func TestMessage_Marshal(t *testing.T) {
+	c := qt.New(t)
+
+	msg := slack.NewMessage("biscuits").
+		Attach(slack.NewAttachment("#36a64f").
+			WithTitle(`Rename "Eater" to Consumer`, "https://github.com/spaceweasel/jeff-test/pull/14").
+			AddField("Labels", "custard, spoons", true).
+			WithFooter(slack.Link("https://github.com/spaceweasel/jeff-test", "spaceweasel/jeff-test"), 1662454800)).
+		AddBlock(slack.Section("Bowls & <spoons>")).
+		AddBlock(slack.Context(slack.MarkdownElement("jeff")))
+
+	b, err := json.Marshal(msg)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.JSONEquals, map[string]any{
+		"channel": "biscuits",
+		"blocks": []any{
+			map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": "Bowls & <spoons>"}},
+			map[string]any{"type": "context", "elements": []any{map[string]any{"type": "mrkdwn", "text": "jeff"}}},
+		},
+		"attachments": []any{map[string]any{
+			"mrkdwn_in":   []any{"text", "pretext", "fields"},
+			"color":       "#36a64f",
+			"pretext":     "",
+			"title":       `Rename "Eater" to Consumer`,
+			"title_link":  "https://github.com/spaceweasel/jeff-test/pull/14",
+			"text":        "",
+			"fields":      []any{map[string]any{"title": "Labels", "value": "custard, spoons", "short": true}},
+			"footer":      "<https://github.com/spaceweasel/jeff-test|spaceweasel/jeff-test>",
+			"footer_icon": slack.DefaultFooterIcon,
+			"ts":          1662454800,
+		}},
+	})
+}
+
+func TestLink(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(slack.Link("https://github.com/jeff", "Jeff <jeff@example.com> & co"), qt.Equals,
+		"<https://github.com/jeff|Jeff &lt;jeff@example.com&gt; &amp; co>")
+}
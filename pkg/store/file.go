@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File keeps refs in a JSON file, which can be persisted between workflow
+// runs with a cache.
+type File struct {
+	mu   sync.Mutex
+	path string
+	now  func() time.Time
+}
+
+func NewFile(path string) *File {
+	return &File{
+		path: path,
+		now:  time.Now,
+	}
+}
+
+func (f *File) Get(ctx context.Context, key string) (Ref, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.load()
+	if err != nil {
+		return Ref{}, false, err
+	}
+	e, ok := entries[key]
+	if !ok || !f.now().Before(e.Expires) {
+		return Ref{}, false, nil
+	}
+	return e.Ref, true, nil
+}
+
+func (f *File) Put(ctx context.Context, key string, ref Ref, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.load()
+	if err != nil {
+		return err
+	}
+	now := f.now()
+	for k, e := range entries {
+		if !now.Before(e.Expires) {
+			delete(entries, k)
+		}
+	}
+	entries[key] = entry{Ref: ref, Expires: now.Add(ttl)}
+
+	return f.save(entries)
+}
+
+func (f *File) load() (map[string]entry, error) {
+	entries := make(map[string]entry)
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read store, %w", err)
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse store, %w", err)
+	}
+	return entries, nil
+}
+
+// save writes the entries to a temporary file first, so the store is never
+// left half written.
+func (f *File) save(entries map[string]entry) error {
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("could not write store, %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("could not write store, %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write store, %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write store, %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("could not write store, %w", err)
+	}
+	return nil
+}
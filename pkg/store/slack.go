@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a message from the history of a channel.
+type Message struct {
+	TS       string   `json:"ts"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// Metadata is Slack message metadata, which isn't shown to users.
+type Metadata struct {
+	EventType    string         `json:"event_type"`
+	EventPayload map[string]any `json:"event_payload"`
+}
+
+// History lists the messages posted to a channel since a time.
+type History interface {
+	History(ctx context.Context, channel string, since time.Time) ([]Message, error)
+}
+
+const metadataEventType = "slackhub_ref"
+
+// Slack keeps refs in the metadata of the messages themselves, found by
+// searching the history of the channel, so nothing needs persisting between
+// runs. Messages must be posted with the metadata for their keys.
+type Slack struct {
+	h       History
+	channel string
+	maxAge  time.Duration
+	now     func() time.Time
+}
+
+// NewSlack returns a store searching the history of a channel, given by ID as
+// conversations.history doesn't accept names, up to maxAge back.
+func NewSlack(h History, channel string, maxAge time.Duration) *Slack {
+	return &Slack{
+		h:       h,
+		channel: channel,
+		maxAge:  maxAge,
+		now:     time.Now,
+	}
+}
+
+// ForChannel returns a store searching the history of another channel, such as
+// a direct message, which must be given by ID.
+func (s *Slack) ForChannel(channel string) Store {
+	other := *s
+	other.channel = channel
+	return &other
+}
+
+// Counted is a key stored along with a count, such as of the events
+// coalesced into a message, which expires after its own ttl.
+type Counted struct {
+	Key   string
+	Count int
+	TTL   time.Duration
+}
+
+// Metadata returns the metadata to post a message with, storing refs to it
+// for the keys until the ttl has passed, and for any counted keys.
+func (s *Slack) Metadata(keys []string, ttl time.Duration, counted ...Counted) Metadata {
+	now := s.now()
+	ks := make([]any, 0, len(keys))
+	for _, k := range keys {
+		ks = append(ks, k)
+	}
+	payload := map[string]any{
+		"keys":    ks,
+		"expires": float64(now.Add(ttl).Unix()),
+	}
+	if len(counted) > 0 {
+		counts := make(map[string]any, len(counted))
+		for _, c := range counted {
+			counts[c.Key] = map[string]any{
+				"count":   float64(c.Count),
+				"expires": float64(now.Add(c.TTL).Unix()),
+			}
+		}
+		payload["counts"] = counts
+	}
+
+	return Metadata{EventType: metadataEventType, EventPayload: payload}
+}
+
+func (s *Slack) Get(ctx context.Context, key string) (Ref, bool, error) {
+	msgs, err := s.history(ctx)
+	if err != nil {
+		return Ref{}, false, err
+	}
+
+	now := s.now().Unix()
+	for _, m := range msgs {
+		if m.Metadata.EventType != metadataEventType {
+			continue
+		}
+		for _, k := range liveKeys(m.Metadata, now) {
+			if k == key {
+				return Ref{Channel: s.channel, TS: m.TS}, true, nil
+			}
+		}
+		counts, _ := m.Metadata.EventPayload["counts"].(map[string]any)
+		if c, ok := counts[key].(map[string]any); ok {
+			expires, _ := c["expires"].(float64)
+			if int64(expires) <= now {
+				continue
+			}
+			count, _ := c["count"].(float64)
+			return Ref{Channel: s.channel, TS: m.TS, Count: int(count)}, true, nil
+		}
+	}
+
+	return Ref{}, false, nil
+}
+
+// Keys returns the keys of refs to a message which haven't expired, so that
+// they can be kept when it is updated, as its metadata is replaced.
+func (s *Slack) Keys(ctx context.Context, ref Ref) ([]string, error) {
+	msgs, err := s.history(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range msgs {
+		if m.TS == ref.TS {
+			return liveKeys(m.Metadata, s.now().Unix()), nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Slack) history(ctx context.Context) ([]Message, error) {
+	return s.h.History(ctx, s.channel, s.now().Add(-s.maxAge))
+}
+
+// liveKeys returns the keys of refs in message metadata which haven't
+// expired.
+func liveKeys(md Metadata, now int64) []string {
+	if md.EventType != metadataEventType {
+		return nil
+	}
+	expires, _ := md.EventPayload["expires"].(float64)
+	if int64(expires) <= now {
+		return nil
+	}
+	var keys []string
+	ks, _ := md.EventPayload["keys"].([]any)
+	for _, k := range ks {
+		if k, ok := k.(string); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Put does nothing, as the ref is stored with the message when posted.
+func (s *Slack) Put(ctx context.Context, key string, ref Ref, ttl time.Duration) error {
+	return nil
+}
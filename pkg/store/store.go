@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Ref identifies a posted message, so that it can be threaded under or
+// updated later.
+type Ref struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts,omitempty"`
+	// Count is the number of commits, or updates, covered by a message
+	// coalescing a burst of events.
+	Count int `json:"count,omitempty"`
+}
+
+// Store keeps message refs by key, such as the message announcing a pull
+// request, or that an event has already been posted.
+type Store interface {
+	// Get returns the ref stored for key, reporting whether it was found
+	// and hasn't expired.
+	Get(ctx context.Context, key string) (Ref, bool, error)
+	// Put stores a ref for key until the ttl has passed.
+	Put(ctx context.Context, key string, ref Ref, ttl time.Duration) error
+}
+
+type entry struct {
+	Ref     Ref       `json:"ref"`
+	Expires time.Time `json:"expires"`
+}
+
+// Memory keeps refs for the lifetime of the process, such as when serving
+// webhooks.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	now     func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (Ref, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok || !m.now().Before(e.Expires) {
+		return Ref{}, false, nil
+	}
+	return e.Ref, true, nil
+}
+
+func (m *Memory) Put(ctx context.Context, key string, ref Ref, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	// drop anything expired, so the map doesn't grow forever
+	for k, e := range m.entries {
+		if !now.Before(e.Expires) {
+			delete(m.entries, k)
+		}
+	}
+	m.entries[key] = entry{Ref: ref, Expires: now.Add(ttl)}
+	return nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestStores(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2022, 9, 6, 11, 10, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	m := NewMemory()
+	m.now = clock
+	f := NewFile(filepath.Join(c.TempDir(), "store.json"))
+	f.now = clock
+
+	for name, s := range map[string]Store{"Memory": m, "File": f} {
+		c.Run(name, func(c *qt.C) {
+			ctx := context.Background()
+			ref := Ref{Channel: "C0123", TS: "1662459000.000100"}
+
+			_, ok, err := s.Get(ctx, "custard")
+			c.Assert(err, qt.IsNil)
+			c.Assert(ok, qt.IsFalse)
+
+			err = s.Put(ctx, "custard", ref, time.Hour)
+			c.Assert(err, qt.IsNil)
+			got, ok, err := s.Get(ctx, "custard")
+			c.Assert(err, qt.IsNil)
+			c.Assert(ok, qt.IsTrue)
+			c.Assert(got, qt.Equals, ref)
+
+			now = now.Add(time.Hour)
+			_, ok, err = s.Get(ctx, "custard")
+			c.Assert(err, qt.IsNil)
+			c.Assert(ok, qt.IsFalse)
+		})
+	}
+}
+
+func TestFile_Persisted(t *testing.T) {
+	c := qt.New(t)
+
+	path := filepath.Join(c.TempDir(), ".slackhub", "store.json")
+	ref := Ref{Channel: "C0123", TS: "1662459000.000100"}
+	err := NewFile(path).Put(context.Background(), "custard", ref, time.Hour)
+	c.Assert(err, qt.IsNil)
+
+	got, ok, err := NewFile(path).Get(context.Background(), "custard")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got, qt.Equals, ref)
+}
+
+type historyFunc func(ctx context.Context, channel string, since time.Time) ([]Message, error)
+
+func (f historyFunc) History(ctx context.Context, channel string, since time.Time) ([]Message, error) {
+	return f(ctx, channel, since)
+}
+
+func TestSlack(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2022, 9, 6, 11, 10, 0, 0, time.UTC)
+	var msgs []Message
+	s := NewSlack(historyFunc(func(ctx context.Context, channel string, since time.Time) ([]Message, error) {
+		c.Check(channel, qt.Equals, "C0123")
+		c.Check(since, qt.Equals, now.Add(-24*time.Hour))
+		return msgs, nil
+	}), "C0123", 24*time.Hour)
+	s.now = func() time.Time { return now }
+
+	msgs = []Message{
+		{TS: "1662459000.000100"},
+		{TS: "1662459000.000200", Metadata: s.Metadata([]string{"custard", "jam"}, time.Hour)},
+	}
+
+	ref, ok, err := s.Get(context.Background(), "jam")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(ref, qt.Equals, Ref{Channel: "C0123", TS: "1662459000.000200"})
+
+	_, ok, err = s.Get(context.Background(), "biscuits")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+
+	now = now.Add(time.Hour)
+	_, ok, err = s.Get(context.Background(), "jam")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+
+	// direct messages are searched for in their own history
+	dm := NewSlack(historyFunc(func(ctx context.Context, channel string, since time.Time) ([]Message, error) {
+		c.Check(channel, qt.Equals, "D0456")
+		return []Message{{TS: "1662459000.000300", Metadata: s.Metadata([]string{"custard"}, time.Hour)}}, nil
+	}), "C0123", 24*time.Hour)
+	dm.now = func() time.Time { return now }
+	ref, ok, err = dm.ForChannel("D0456").Get(context.Background(), "custard")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(ref, qt.Equals, Ref{Channel: "D0456", TS: "1662459000.000300"})
+}
+
+func TestSlack_Counted(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2022, 9, 6, 11, 10, 0, 0, time.UTC)
+	var msgs []Message
+	s := NewSlack(historyFunc(func(ctx context.Context, channel string, since time.Time) ([]Message, error) {
+		return msgs, nil
+	}), "C0123", 24*time.Hour)
+	s.now = func() time.Time { return now }
+
+	msgs = []Message{
+		{TS: "1662459000.000100", Metadata: s.Metadata([]string{"custard"}, time.Hour, Counted{Key: "jam", Count: 3, TTL: 10 * time.Minute})},
+	}
+
+	ref, ok, err := s.Get(context.Background(), "jam")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(ref, qt.Equals, Ref{Channel: "C0123", TS: "1662459000.000100", Count: 3})
+
+	keys, err := s.Keys(context.Background(), ref)
+	c.Assert(err, qt.IsNil)
+	c.Assert(keys, qt.DeepEquals, []string{"custard"})
+
+	// counted keys expire by themselves
+	now = now.Add(10 * time.Minute)
+	_, ok, err = s.Get(context.Background(), "jam")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+	_, ok, err = s.Get(context.Background(), "custard")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+}